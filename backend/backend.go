@@ -3,14 +3,22 @@ package backend
 import (
 	"os"
 	"path"
+	"strings"
+	"time"
 
+	"github.com/eyeamera/stacker-cli/client"
 	"github.com/eyeamera/stacker-cli/stacker"
 )
 
 type backend struct {
-	f *fetcher
+	f   *fetcher
+	c   *checker
+	env string
 }
 
+// defaultCmdTimeout limits how long commands run by the Cmd resolver may take
+const defaultCmdTimeout = 30 * time.Second
+
 var backendPaths = []string{
 	"environments",
 	"regions",
@@ -29,25 +37,91 @@ func New(dir string) *backend {
 
 	cs := newConfigStore(confDir)
 	ts := newTemplateStore(path.Join(dir, "templates"))
+	ps := newPolicyStore(path.Join(dir, "policies"))
 
 	r := NewParamsResolver()
-	r.Add("Stack", ResolveStackOutput)
+	r.Add("Stack", NewStackOutputResolver(client.NewCloudformationClientWithProfile))
+	r.Add("Export", NewExportResolver(client.NewCloudformationClientWithProfile))
+	r.AddSensitive("Secret", NewSecretResolver(client.NewSecretsManagerClient))
 	r.Add("File", ResolveFile)
+	r.Add("Env", ResolveEnv)
+	r.Add("Cmd", NewCmdResolver(dir, defaultCmdTimeout))
+	r.AddSensitive("SSM", NewSSMResolver(client.NewSSMClient))
+	r.Add("Previous", ResolvePrevious)
+
+	p := newPackager(dir, func(region, profile string) (client.Uploader, error) {
+		s3, err := client.NewS3Client(region, profile)
+		if err != nil {
+			return nil, err
+		}
+		return client.NewS3Uploader(s3, region), nil
+	})
 
-	f := newFetcher(cs, ts, r)
+	f := newFetcher(cs, ts, ps, r, p)
 
-	return &backend{f: f}
+	return &backend{f: f, c: newChecker(cs, ts, r)}
+}
+
+// SetEnv limits all fetched stacks to those within an environment path
+func (b *backend) SetEnv(env string) {
+	b.env = env
 }
 
 func (b *backend) FetchAll() ([]stacker.Stack, error) {
+	if b.env != "" {
+		return b.f.FetchEnv(b.env)
+	}
+	return b.f.FetchAll()
+}
+
+// FetchEveryEnv fetches the stacks of every environment, regardless of the
+// environment path set with SetEnv
+func (b *backend) FetchEveryEnv() ([]stacker.Stack, error) {
 	return b.f.FetchAll()
 }
 
 func (b *backend) Fetch(name string) ([]stacker.Stack, error) {
-	return b.f.Fetch(name)
+	return b.f.Fetch(b.withinEnv(name, false))
+}
+
+func (b *backend) FetchEnv(env string) ([]stacker.Stack, error) {
+	return b.f.FetchEnv(b.withinEnv(env, true))
+}
+
+// withinEnv prefixes a selector with the environment path, unless the selector
+// already lies within it, such as `production/Foo-VPC` with an environment of
+// `production`. An environment path selects itself when orEqual is set.
+func (b *backend) withinEnv(selector string, orEqual bool) string {
+	env := strings.Trim(b.env, "/")
+	if env == "" {
+		return selector
+	}
+
+	trimmed := strings.Trim(selector, "/")
+	if strings.HasPrefix(trimmed, env+"/") || (orEqual && trimmed == env) {
+		return selector
+	}
+	return path.Join(b.env, selector)
 }
 
 func pathExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+// FetchPolicy returns the body of a stack policy within the policies directory
+func (b *backend) FetchPolicy(name string) (string, error) {
+	return b.f.FetchPolicy(name)
+}
+
+// Check validates every environment file and template without making any AWS
+// calls, returning a description of each problem found
+func (b *backend) Check() ([]string, error) {
+	return b.c.Check()
+}
+
+// Render returns the effective configuration of a fetched stack, resolving its
+// parameters unless offline is set
+func (b *backend) Render(stack stacker.Stack, offline bool) (*stacker.RenderedStack, error) {
+	return b.f.Render(stack, offline)
+}
@@ -0,0 +1,33 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackendWithinEnv(t *testing.T) {
+	cases := []struct {
+		env      string
+		selector string
+		orEqual  bool
+
+		expected string
+	}{
+		{"", "production/Foo-VPC", false, "production/Foo-VPC"},
+		{"production", "Foo-VPC", false, "production/Foo-VPC"},
+		{"production", "vpc/Foo-VPC", false, "production/vpc/Foo-VPC"},
+		{"production", "production/Foo-VPC", false, "production/Foo-VPC"},
+		{"/production/", "production/vpc/Foo-VPC", false, "production/vpc/Foo-VPC"},
+		{"production", "production", false, "production/production"},
+		{"production", "production", true, "production"},
+		{"production", "production/vpc", true, "production/vpc"},
+		{"production", "vpc", true, "production/vpc"},
+		{"production/vpc", "production/Foo-VPC", false, "production/vpc/production/Foo-VPC"},
+	}
+
+	for _, c := range cases {
+		b := &backend{env: c.env}
+		assert.Equal(t, c.expected, b.withinEnv(c.selector, c.orEqual), "env %s selector %s", c.env, c.selector)
+	}
+}
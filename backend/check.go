@@ -0,0 +1,233 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+// validCapabilities are the capabilities cloudformation accepts
+var validCapabilities = []string{
+	"CAPABILITY_IAM",
+	"CAPABILITY_NAMED_IAM",
+	"CAPABILITY_AUTO_EXPAND",
+}
+
+// problem is an issue found within a stacker directory, located by file and
+// line where possible
+type problem struct {
+	file    string
+	line    int
+	message string
+}
+
+func (p problem) String() string {
+	switch {
+	case p.file == "":
+		return p.message
+	case p.line == 0:
+		return fmt.Sprintf("%s: %s", p.file, p.message)
+	default:
+		return fmt.Sprintf("%s:%d: %s", p.file, p.line, p.message)
+	}
+}
+
+// checker validates a stacker directory without making any AWS calls
+type checker struct {
+	cs *configStore
+	ts *templateStore
+	pr *paramsResolver
+}
+
+func newChecker(cs *configStore, ts *templateStore, pr *paramsResolver) *checker {
+	return &checker{cs, ts, pr}
+}
+
+// Check returns a description of every problem within the environment files
+// and templates. An error is returned when an environment file can't be
+// loaded at all.
+func (c *checker) Check() ([]string, error) {
+	if err := c.cs.load(); err != nil {
+		return nil, err
+	}
+
+	problems := c.checkTemplates()
+
+	keys := make([]string, 0, len(c.cs.d))
+	for k := range c.cs.d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	defined := make(map[string]string) // stack key => location
+	for _, key := range keys {
+		problems = append(problems, c.checkConfig(key, defined)...)
+	}
+
+	descriptions := make([]string, len(problems))
+	for i, p := range problems {
+		descriptions[i] = p.String()
+	}
+	return descriptions, nil
+}
+
+// checkTemplates parses every template within the templates directory
+func (c *checker) checkTemplates() []problem {
+	problems := make([]problem, 0)
+
+	filepath.Walk(c.ts.path, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || !contains(templateExtensions, filepath.Ext(path)) {
+			return nil
+		}
+
+		if _, err := parseTemplate(path, c.ts.dir); err != nil {
+			problems = append(problems, problem{message: err.Error()})
+		}
+		return nil
+	})
+
+	return problems
+}
+
+// checkConfig checks each stack within an environment file. Stacks are
+// recorded in defined so that stacks sharing a name and region are reported.
+func (c *checker) checkConfig(key string, defined map[string]string) []problem {
+	var (
+		problems = make([]problem, 0)
+		file     = c.cs.files[key]
+		config   = c.cs.d[key]
+		root     = readNode(file)
+	)
+
+	report := func(message string, path ...interface{}) {
+		problems = append(problems, problem{file: file, line: nodeLine(root, path...), message: message})
+	}
+
+	for _, k := range sortedKeys(config.Defaults.Parameters) {
+		if err := c.pr.check(config.Defaults.Parameters[k]); err != nil {
+			report(fmt.Sprintf("parameter `%s`: %s", k, err), "defaults", "parameters", k)
+		}
+	}
+
+	for i, st := range config.Stacks {
+		for j, capability := range st.Capabilities {
+			if !contains(validCapabilities, capability) {
+				report(fmt.Sprintf(
+					"stack `%s` has invalid capability `%s`, expected one of %s",
+					st.Name, capability, strings.Join(validCapabilities, ", "),
+				), "stacks", i, "capabilities", j)
+			}
+		}
+
+		for _, k := range sortedKeys(st.Parameters) {
+			if err := c.pr.check(st.Parameters[k]); err != nil {
+				report(fmt.Sprintf("stack `%s` parameter `%s`: %s", st.Name, k, err), "stacks", i, "parameters", k)
+			}
+		}
+
+		// Resolved after checking parameters, as inherited parameters are
+		// merged into the stack's own
+		resolved := c.cs.resolveStack(key, st)
+
+		p, ok := c.ts.find(resolved.TemplateName)
+		if !ok {
+			report(fmt.Sprintf("stack `%s` uses template `%s` which does not exist", st.Name, resolved.TemplateName), "stacks", i, "template_name")
+		}
+
+		// Templated files can only be parsed once rendered with a stack's vars
+		if ok && strings.HasSuffix(p, templateSuffix) {
+			if _, err := c.ts.Fetch(resolved.TemplateName, resolved.TemplateVars); err != nil {
+				report(fmt.Sprintf("stack `%s`: %s", st.Name, err), "stacks", i, "template_vars")
+			}
+		}
+
+		location := fmt.Sprintf("%s:%d", file, nodeLine(root, "stacks", i, "name"))
+		// Stacks of the same name may be deployed to other accounts
+		sk := stacker.StackRef{Name: st.Name, Region: resolved.Region, Profile: resolved.Profile}.Key()
+		if previous, ok := defined[sk]; ok {
+			where := resolved.Region
+			if resolved.Profile != "" {
+				where += " with profile " + resolved.Profile
+			}
+			report(fmt.Sprintf("stack `%s` in %s is already defined at %s", st.Name, where, previous), "stacks", i, "name")
+			continue
+		}
+		defined[sk] = location
+	}
+
+	return problems
+}
+
+// readNode parses a YAML file into a node tree, which records the line of
+// each value. Nil is returned when the file can't be parsed.
+func readNode(path string) *yaml.Node {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil
+	}
+	return &root
+}
+
+// nodeLine returns the line of the node found by following a path of mapping
+// keys and sequence indexes, or that of its closest ancestor when the path
+// doesn't exist. Zero is returned for a nil node.
+func nodeLine(n *yaml.Node, path ...interface{}) int {
+	if n == nil {
+		return 0
+	}
+
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+
+	line := n.Line
+	for _, p := range path {
+		var next *yaml.Node
+
+		switch k := p.(type) {
+		case string:
+			if n.Kind != yaml.MappingNode {
+				break
+			}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == k {
+					line, next = n.Content[i].Line, n.Content[i+1]
+					break
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && k < len(n.Content) {
+				next = n.Content[k]
+				line = next.Line
+			}
+		}
+
+		if next == nil {
+			break
+		}
+		n = next
+	}
+
+	return line
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const TestInvalidDir = "../test/invalid"
+
+func TestCheckerCheck(t *testing.T) {
+	pr := NewParamsResolver()
+	pr.Add("Stack", ResolveFile)
+
+	c := newChecker(
+		newConfigStore(TestInvalidDir+"/environments"),
+		newTemplateStore(TestInvalidDir+"/templates"),
+		pr,
+	)
+
+	problems, err := c.Check()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"invalid template ../test/invalid/templates/Broken.json: invalid JSON: unexpected end of JSON input",
+		"../test/invalid/partials/second.yml:1: include cycle detected: " +
+			"../test/invalid/templates/Cycle.yml -> ../test/invalid/partials/first.yml -> ../test/invalid/partials/second.yml -> ../test/invalid/partials/first.yml",
+		"../test/invalid/templates/Partial.yml:3: partial ../test/invalid/partials/alarm.yml does not exist",
+		"../test/invalid/environments/app.yml:4: parameter `Shared`: unknown resolver `Vault`",
+		"../test/invalid/environments/app.yml:9: stack `API` has invalid capability `CAPABILITIES_IAM`, expected one of CAPABILITY_IAM, CAPABILITY_NAMED_IAM, CAPABILITY_AUTO_EXPAND",
+		"../test/invalid/environments/app.yml:14: stack `API` parameter `Url`: unknown resolver `Host` in substitution ${Host:api}",
+		"../test/invalid/environments/app.yml:17: stack `Worker` uses template `Missing` which does not exist",
+		"../test/invalid/environments/app.yml:18: stack `Scheduler` uses template `Scheduler` which does not exist",
+		"../test/invalid/environments/app.yml:20: stack `Queue`: unable to render template ../test/invalid/templates/Queue.yml.tmpl: template: Queue.yml.tmpl:6:20: executing \"Queue.yml.tmpl\" at <.QueueName>: map has no entry for key \"QueueName\"",
+		"../test/invalid/environments/app/api.yml:2: stack `API` in us-east-1 is already defined at ../test/invalid/environments/app.yml:7",
+	}, problems)
+}
+
+func TestCheckerCheckValid(t *testing.T) {
+	pr := NewParamsResolver()
+	pr.Add("Stack", ResolveFile)
+
+	c := newChecker(newConfigStore(TestEnvsDir), newTemplateStore(TestTemplatesDir), pr)
+
+	problems, err := c.Check()
+
+	assert.Nil(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestNodeLine(t *testing.T) {
+	root := readNode(TestInvalidDir + "/environments/app.yml")
+
+	assert.Equal(t, 1, nodeLine(root))
+	assert.Equal(t, 7, nodeLine(root, "stacks", 0))
+	assert.Equal(t, 16, nodeLine(root, "stacks", 1, "name"))
+	assert.Equal(t, 16, nodeLine(root, "stacks", 1, "missing"))
+	assert.Equal(t, 6, nodeLine(root, "stacks", 5))
+	assert.Equal(t, 0, nodeLine(nil, "stacks"))
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -19,8 +20,11 @@ type config struct {
 }
 
 type defaults struct {
-	Region     string
-	Parameters map[string]interface{}
+	Region       string
+	Parameters   map[string]interface{}
+	Tags         map[string]string
+	TemplateVars map[string]interface{} `yaml:"template_vars"`
+	stackOptions `yaml:",inline"`
 }
 
 type stackConfig struct {
@@ -29,11 +33,52 @@ type stackConfig struct {
 	TemplateName string `yaml:"template_name"`
 	Capabilities []string
 	Parameters   map[string]interface{}
+	Tags         map[string]string
+	TemplateVars map[string]interface{} `yaml:"template_vars"`
+	stackOptions `yaml:",inline"`
+
+	file    string            // Environment file declaring the stack
+	sources map[string]string // Parameter => environment file it's set in
+}
+
+// stackOptions are cloudformation settings which may be declared on a stack
+// or inherited from the defaults of a parent config file
+type stackOptions struct {
+	Profile               string          `yaml:"profile"` // Named profile from the shared aws config
+	RoleARN               string          `yaml:"role_arn"`
+	NotificationARNs      []string        `yaml:"notification_arns"`
+	RollbackConfiguration *rollbackConfig `yaml:"rollback_configuration"`
+	TerminationProtection *bool           `yaml:"termination_protection"`
+	StackPolicy           interface{}     `yaml:"stack_policy"` // Policy name, or an inline document
+	ArtifactBucket        *artifactBucket `yaml:"artifact_bucket"`
+}
+
+type rollbackConfig struct {
+	MonitoringTime int64 `yaml:"monitoring_time"`
+	Alarms         []string
+}
+
+// artifactBucket is declared either as a bucket name, or as a mapping which
+// may also provide a key prefix and force templates to always be uploaded
+type artifactBucket struct {
+	Name   string
+	Prefix string
+	Always bool
+}
+
+func (b *artifactBucket) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&b.Name); err == nil {
+		return nil
+	}
+
+	type plain artifactBucket
+	return unmarshal((*plain)(b))
 }
 
 type ConfigStore interface {
 	FetchAll() ([]stackConfig, error)
 	Fetch(name string) ([]stackConfig, error)
+	FetchEnv(env string) ([]stackConfig, error)
 }
 
 // storeMap stores a map of the relative config path with extensions removed
@@ -43,8 +88,9 @@ type configStoreMap map[string]config
 // store provides a way for retrieving configuration files
 // and stack configs from the filesystem
 type configStore struct {
-	path string
-	d    configStoreMap
+	path  string
+	d     configStoreMap
+	files map[string]string // config path => file path
 }
 
 func newConfigStore(path string) *configStore {
@@ -52,17 +98,26 @@ func newConfigStore(path string) *configStore {
 }
 
 func (s *configStore) FetchAll() ([]stackConfig, error) {
-	return s.fetch(nil)
+	return s.fetch("", nil)
+}
+
+// Fetch returns the stacks matching a selector, which is either a stack name
+// or a stack name prefixed with an environment path, e.g. `production/Foo-VPC`
+func (s *configStore) Fetch(selector string) ([]stackConfig, error) {
+	env, name := splitSelector(selector)
+	return s.fetch(env, &name)
 }
 
-func (s *configStore) Fetch(name string) ([]stackConfig, error) {
-	return s.fetch(&name)
+// FetchEnv returns the stacks defined within an environment path, such as
+// `production` or `production/vpc`, including those in nested config files
+func (s *configStore) FetchEnv(env string) ([]stackConfig, error) {
+	return s.fetch(env, nil)
 }
 
-// Fetch returns a slice of stacks that match the provided stack name.
-// Each stackConfig will contain all of the inherited parameters from
-// the parent config file(s)
-func (s *configStore) fetch(name *string) ([]stackConfig, error) {
+// Fetch returns a slice of stacks within the environment path that match the
+// provided stack name. Each stackConfig will contain all of the inherited
+// parameters from the parent config file(s)
+func (s *configStore) fetch(env string, name *string) ([]stackConfig, error) {
 	// Load the config files from disk
 	if err := s.load(); err != nil {
 		return nil, err
@@ -71,6 +126,10 @@ func (s *configStore) fetch(name *string) ([]stackConfig, error) {
 	scs := make([]stackConfig, 0)
 
 	for path, config := range s.d {
+		if !inEnv(path, env) {
+			continue
+		}
+
 		for _, stack := range config.Stacks {
 			if name == nil || stack.Name == *name {
 				scs = append(scs, s.resolveStack(path, stack))
@@ -81,10 +140,54 @@ func (s *configStore) fetch(name *string) ([]stackConfig, error) {
 	return scs, nil
 }
 
-// resolveStack takes a path and a stackConfig and merges default parameters
-// into a returned stackConfig
+// splitSelector separates a stack selector into its environment path and
+// stack name
+func splitSelector(selector string) (string, string) {
+	selector = filepath.ToSlash(selector)
+	i := strings.LastIndex(selector, "/")
+	if i < 0 {
+		return "", selector
+	}
+	return selector[:i], selector[i+1:]
+}
+
+// inEnv returns whether a config path resides within an environment path.
+// An empty environment path matches every config path.
+func inEnv(path string, env string) bool {
+	env = strings.Trim(filepath.ToSlash(env), "/")
+	path = filepath.ToSlash(path)
+	return env == "" || path == env || strings.HasPrefix(path, env+"/")
+}
+
+// resolveStack takes a path and a stackConfig and merges default parameters,
+// tags and template vars into a returned stackConfig, recording the environment file each
+// parameter is set in
 func (s *configStore) resolveStack(path string, st stackConfig) stackConfig {
 	stack := st
+	stack.file = s.files[path]
+	stack.sources = make(map[string]string)
+
+	// Copy parameters, tags and template vars, so that defaults aren't merged into the
+	// loaded config
+	stack.Parameters = make(map[string]interface{})
+	for k, v := range st.Parameters {
+		stack.Parameters[k] = v
+		stack.sources[k] = stack.file
+	}
+
+	// Tags are left nil unless declared by the stack or its defaults, so that
+	// tags set on a deployed stack outside of stacker are left in place
+	if st.Tags != nil {
+		stack.Tags = make(map[string]string)
+		for k, v := range st.Tags {
+			stack.Tags[k] = v
+		}
+	}
+
+	stack.TemplateVars = make(map[string]interface{})
+	for k, v := range st.TemplateVars {
+		stack.TemplateVars[k] = v
+	}
 
 	// Apply default parameters from parent config paths
 	for path != "." {
@@ -98,8 +201,32 @@ func (s *configStore) resolveStack(path string, st stackConfig) stackConfig {
 			stack.Region = c.Defaults.Region
 		}
 
-		if stack.Parameters == nil {
-			stack.Parameters = map[string]interface{}{}
+		if stack.Profile == "" {
+			stack.Profile = c.Defaults.Profile
+		}
+
+		if stack.RoleARN == "" {
+			stack.RoleARN = c.Defaults.RoleARN
+		}
+
+		if stack.NotificationARNs == nil {
+			stack.NotificationARNs = c.Defaults.NotificationARNs
+		}
+
+		if stack.RollbackConfiguration == nil {
+			stack.RollbackConfiguration = c.Defaults.RollbackConfiguration
+		}
+
+		if stack.TerminationProtection == nil {
+			stack.TerminationProtection = c.Defaults.TerminationProtection
+		}
+
+		if stack.StackPolicy == nil {
+			stack.StackPolicy = c.Defaults.StackPolicy
+		}
+
+		if stack.ArtifactBucket == nil {
+			stack.ArtifactBucket = c.Defaults.ArtifactBucket
 		}
 
 		if stack.TemplateName == "" {
@@ -111,6 +238,24 @@ func (s *configStore) resolveStack(path string, st stackConfig) stackConfig {
 				continue
 			}
 			stack.Parameters[k] = v
+			stack.sources[k] = s.files[path]
+		}
+
+		if stack.Tags == nil && c.Defaults.Tags != nil {
+			stack.Tags = make(map[string]string)
+		}
+		for k, v := range c.Defaults.Tags {
+			if _, ok := stack.Tags[k]; ok {
+				continue
+			}
+			stack.Tags[k] = v
+		}
+
+		for k, v := range c.Defaults.TemplateVars {
+			if _, ok := stack.TemplateVars[k]; ok {
+				continue
+			}
+			stack.TemplateVars[k] = v
 		}
 
 		// Lop a segment off the path and continue...
@@ -134,6 +279,7 @@ func (s *configStore) load() error {
 	}
 
 	s.d = make(configStoreMap)
+	s.files = make(map[string]string)
 
 	// Walk all the files in the config path
 	return filepath.Walk(s.path, func(path string, info os.FileInfo, err error) error {
@@ -159,6 +305,7 @@ func (s *configStore) load() error {
 		}
 
 		s.d[key] = c
+		s.files[key] = path
 
 		return nil
 	})
@@ -12,8 +12,9 @@ func TestConfigStoreInternalFetch(t *testing.T) {
 	s := newConfigStore(TestEnvsDir)
 	assert.Nil(t, s.d)
 
-	s.fetch(nil)
+	s.fetch("", nil)
 
+	enabled, disabled := true, false
 	expected := configStoreMap{
 		"production": config{
 			Defaults: defaults{
@@ -22,6 +23,25 @@ func TestConfigStoreInternalFetch(t *testing.T) {
 					"VpcCIDR": "10.21.0.0/16",
 					"Bar":     "123abc",
 				},
+				Tags: map[string]string{
+					"Env":  "production",
+					"Team": "platform",
+				},
+				TemplateVars: map[string]interface{}{
+					"Environment": "production",
+					"Zones":       []interface{}{"us-west-2a", "us-west-2b"},
+				},
+				stackOptions: stackOptions{
+					Profile:               "production",
+					RoleARN:               "arn:aws:iam::123456789012:role/cloudformation",
+					NotificationARNs:      []string{"arn:aws:sns:us-west-2:123456789012:stack-events"},
+					TerminationProtection: &enabled,
+					ArtifactBucket: &artifactBucket{
+						Name:   "production-artifacts-us-west-2",
+						Prefix: "stacker",
+						Always: true,
+					},
+				},
 			},
 		},
 		"production/vpc": config{
@@ -33,24 +53,36 @@ func TestConfigStoreInternalFetch(t *testing.T) {
 				stackConfig{
 					Name:         "Foo-VPC",
 					TemplateName: "VPC",
-					Capabilities: []string{"CAPABILITIES_IAM"},
+					Capabilities: []string{"CAPABILITY_IAM"},
 					Parameters: map[string]interface{}{
-						"Bar":     "123abc",
-						"Name":    "ProductionVPC",
-						"VpcCIDR": "10.21.0.0/16",
+						"Name": "ProductionVPC",
+					},
+					Tags: map[string]string{
+						"Team": "network",
+					},
+					TemplateVars: map[string]interface{}{
+						"Zones": []interface{}{"us-west-2a", "us-west-2b", "us-west-2c"},
+					},
+					stackOptions: stackOptions{
+						TerminationProtection: &disabled,
+						RollbackConfiguration: &rollbackConfig{
+							MonitoringTime: 10,
+							Alarms:         []string{"arn:aws:cloudwatch:us-west-2:123456789012:alarm:nat-errors"},
+						},
 					},
 				},
 			},
 		},
 		"sandbox": config{
 			Defaults: defaults{
-				Region:     "us-east-2",
-				Parameters: map[string]interface{}(nil),
+				Region:       "us-east-2",
+				Parameters:   map[string]interface{}(nil),
+				stackOptions: stackOptions{ArtifactBucket: &artifactBucket{Name: "sandbox-artifacts-us-east-2"}},
 			},
 			Stacks: []stackConfig{
 				stackConfig{Name: "Foo-VPC",
 					TemplateName: "VPC",
-					Capabilities: []string{"CAPABILITIES_IAM"},
+					Capabilities: []string{"CAPABILITY_IAM"},
 					Parameters: map[string]interface{}{
 						"VpcCIDR": "10.11.0.0/16",
 						"Name":    "SandboxVPC",
@@ -76,7 +108,7 @@ func TestConfigStoreFetch(t *testing.T) {
 	// 		Name:         "Foo-VPC",
 	// 		Region:       "us-west-2", // inherited from 'production'
 	// 		TemplateName: "VPC",
-	// 		Capabilities: "CAPABILITIES_IAM",
+	// 		Capabilities: "CAPABILITY_IAM",
 	// 		Parameters: map[string]interface{}{
 	// 			"Name":    "ProductionVPC",
 	// 			"VpcCIDR": "10.21.0.0/16", // inherited from 'production/vpc'
@@ -87,7 +119,7 @@ func TestConfigStoreFetch(t *testing.T) {
 	// 		Name:         "Foo-VPC",
 	// 		Region:       "us-east-1", // inherited from 'sandbox'
 	// 		TemplateName: "VPC",
-	// 		Capabilities: "CAPABILITIES_IAM",
+	// 		Capabilities: "CAPABILITY_IAM",
 	// 		Parameters: map[string]interface{}{
 	// 			"Name":    "SandboxVPC",
 	// 			"VpcCIDR": "10.11.0.0/16",
@@ -97,3 +129,146 @@ func TestConfigStoreFetch(t *testing.T) {
 
 	// assert.EqualValues(t, expected, stacks)
 }
+
+func TestConfigStoreFetchEnv(t *testing.T) {
+	cases := []struct {
+		env     string
+		regions []string
+	}{
+		{"sandbox", []string{"us-east-2"}},
+		{"production", []string{"us-west-2"}},
+		{"production/vpc", []string{"us-west-2"}},
+		{"/production/", []string{"us-west-2"}},
+		{"", []string{"us-east-2", "us-west-2"}},
+		{"prod", []string{}},
+		{"production/api", []string{}},
+	}
+
+	s := newConfigStore(TestEnvsDir)
+
+	for _, c := range cases {
+		stacks, err := s.FetchEnv(c.env)
+		assert.Nil(t, err)
+
+		regions := make([]string, len(stacks))
+		for i, st := range stacks {
+			regions[i] = st.Region
+		}
+
+		assert.ElementsMatch(t, c.regions, regions, "env %s", c.env)
+	}
+}
+
+func TestConfigStoreFetchSelector(t *testing.T) {
+	cases := []struct {
+		selector string
+		regions  []string
+	}{
+		{"Foo-VPC", []string{"us-east-2", "us-west-2"}},
+		{"production/Foo-VPC", []string{"us-west-2"}},
+		{"production/vpc/Foo-VPC", []string{"us-west-2"}},
+		{"sandbox/Foo-VPC", []string{"us-east-2"}},
+		{"sandbox/Bar-VPC", []string{}},
+	}
+
+	s := newConfigStore(TestEnvsDir)
+
+	for _, c := range cases {
+		stacks, err := s.Fetch(c.selector)
+		assert.Nil(t, err)
+
+		regions := make([]string, len(stacks))
+		for i, st := range stacks {
+			regions[i] = st.Region
+		}
+
+		assert.ElementsMatch(t, c.regions, regions, "selector %s", c.selector)
+	}
+}
+
+func TestConfigStoreFetchTags(t *testing.T) {
+	cases := []struct {
+		selector string
+		tags     map[string]string
+	}{
+		{"production/Foo-VPC", map[string]string{"Env": "production", "Team": "network"}},
+		{"sandbox/Foo-VPC", nil},
+	}
+
+	for _, c := range cases {
+		s := newConfigStore(TestEnvsDir)
+
+		stacks, err := s.Fetch(c.selector)
+		assert.Nil(t, err)
+		assert.Len(t, stacks, 1)
+		assert.Equal(t, c.tags, stacks[0].Tags)
+	}
+}
+
+func TestConfigStoreFetchStackOptions(t *testing.T) {
+	s := newConfigStore(TestEnvsDir)
+
+	stacks, err := s.Fetch("production/Foo-VPC")
+	assert.Nil(t, err)
+	assert.Len(t, stacks, 1)
+
+	disabled := false
+	expected := stackOptions{
+		Profile:               "production",                                    // inherited from 'production'
+		RoleARN:               "arn:aws:iam::123456789012:role/cloudformation", // inherited from 'production'
+		NotificationARNs:      []string{"arn:aws:sns:us-west-2:123456789012:stack-events"},
+		TerminationProtection: &disabled,
+		RollbackConfiguration: &rollbackConfig{
+			MonitoringTime: 10,
+			Alarms:         []string{"arn:aws:cloudwatch:us-west-2:123456789012:alarm:nat-errors"},
+		},
+		ArtifactBucket: &artifactBucket{
+			Name:   "production-artifacts-us-west-2",
+			Prefix: "stacker",
+			Always: true,
+		},
+	}
+	assert.Equal(t, expected, stacks[0].stackOptions)
+
+	stacks, err = s.Fetch("sandbox/Foo-VPC")
+	assert.Nil(t, err)
+	assert.Len(t, stacks, 1)
+	assert.Equal(t, stackOptions{
+		ArtifactBucket: &artifactBucket{Name: "sandbox-artifacts-us-east-2"}, // inherited from 'sandbox'
+	}, stacks[0].stackOptions)
+}
+
+func TestConfigStoreFetchTemplateVars(t *testing.T) {
+	s := newConfigStore(TestEnvsDir)
+
+	stacks, err := s.Fetch("production/Foo-VPC")
+	assert.Nil(t, err)
+	assert.Len(t, stacks, 1)
+	assert.Equal(t, map[string]interface{}{
+		"Environment": "production", // inherited from 'production'
+		"Zones":       []interface{}{"us-west-2a", "us-west-2b", "us-west-2c"},
+	}, stacks[0].TemplateVars)
+
+	stacks, err = s.Fetch("sandbox/Foo-VPC")
+	assert.Nil(t, err)
+	assert.Len(t, stacks, 1)
+	assert.Empty(t, stacks[0].TemplateVars)
+}
+
+func TestConfigStoreFetchSources(t *testing.T) {
+	s := newConfigStore(TestEnvsDir)
+
+	stacks, err := s.Fetch("production/Foo-VPC")
+	assert.Nil(t, err)
+	assert.Len(t, stacks, 1)
+
+	assert.Equal(t, TestEnvsDir+"/production/vpc.yml", stacks[0].file)
+	assert.Equal(t, map[string]string{
+		"Name":    TestEnvsDir + "/production/vpc.yml",
+		"VpcCIDR": TestEnvsDir + "/production/vpc.yml",
+		"Bar":     TestEnvsDir + "/production.yml",
+	}, stacks[0].sources)
+
+	// Inherited parameters aren't merged into the loaded config
+	assert.Equal(t, map[string]interface{}{"Name": "ProductionVPC"}, s.d["production/vpc"].Stacks[0].Parameters)
+}
@@ -2,35 +2,145 @@ package backend
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/eyeamera/stacker-cli/stacker"
 )
 
 type stack struct {
-	name          string
-	region        string
-	capabilities  []string
-	templateBody  string
-	rawParameters RawParams
-	resolver      ParamsResolver
-}
-
-func (s *stack) Name() string           { return s.name }
-func (s *stack) TemplateBody() string   { return s.templateBody }
-func (s *stack) Capabilities() []string { return s.capabilities }
-func (s *stack) Region() string         { return s.region }
+	name                  string
+	region                string
+	profile               string
+	capabilities          []string
+	tags                  map[string]string
+	roleARN               string
+	notificationARNs      []string
+	rollbackConfiguration *stacker.RollbackConfiguration
+	terminationProtection *bool
+	stackPolicy           string
+	artifactBucket        *stacker.ArtifactBucket
+	templateName          string
+	template              Template
+	templateBody          string
+	packagedBody          string
+	packager              Packager
+	rawParameters         RawParams
+	unusedParameters      []string
+	file                  string
+	sources               map[string]string
+	templateVars          map[string]interface{}
+	params                []stacker.StackParam
+	resolver              ParamsResolver
+}
+
+func (s *stack) Name() string            { return s.name }
+func (s *stack) Capabilities() []string  { return s.capabilities }
+func (s *stack) Tags() map[string]string { return s.tags }
+func (s *stack) Region() string          { return s.region }
+func (s *stack) Profile() string         { return s.profile }
+func (s *stack) RoleARN() string         { return s.roleARN }
+func (s *stack) NotificationARNs() []string {
+	return s.notificationARNs
+}
+func (s *stack) RollbackConfiguration() *stacker.RollbackConfiguration {
+	return s.rollbackConfiguration
+}
+func (s *stack) TerminationProtection() *bool {
+	return s.terminationProtection
+}
+func (s *stack) StackPolicy() string { return s.stackPolicy }
+func (s *stack) ArtifactBucket() *stacker.ArtifactBucket {
+	return s.artifactBucket
+}
+
+// TemplateBody returns the stack's template body once the local artifacts it
+// references have been uploaded. Artifacts are packaged once, the first time
+// the body is requested.
+func (s *stack) TemplateBody() (string, error) {
+	if s.packager == nil {
+		return s.templateBody, nil
+	}
+
+	if s.packagedBody == "" {
+		body, err := s.packager.Package(s.template, s)
+		if err != nil {
+			return "", err
+		}
+		s.packagedBody = body
+	}
+
+	return s.packagedBody, nil
+}
+
+// Params resolves the stack's parameters. Parameters are resolved once, the
+// first time they're requested, so that secrets and stack outputs aren't
+// fetched again when a stack is planned and then reviewed.
 func (s *stack) Params() ([]stacker.StackParam, error) {
-	return s.resolver.Resolve(s.rawParameters, s)
+	if s.params != nil {
+		return s.params, nil
+	}
+
+	params, err := s.resolver.Resolve(s.rawParameters, s)
+	if err != nil {
+		return nil, err
+	}
+
+	s.params = params
+	return params, nil
+}
+
+// SensitiveParams returns the keys of parameters whose values mustn't be
+// displayed. Before the parameters are resolved, every parameter using a
+// resolver which may provide secrets is reported, such as any `SSM` parameter
+// since only SecureString parameters are known to be sensitive once fetched.
+func (s *stack) SensitiveParams() []string {
+	keys := make([]string, 0)
+
+	if s.params != nil {
+		for _, p := range s.params {
+			if p.Sensitive() {
+				keys = append(keys, p.Key())
+			}
+		}
+		return keys
+	}
+
+	for k, v := range s.rawParameters {
+		if s.resolver.Sensitive(v) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PreviousParams returns the keys of parameters which keep the value they have
+// on the deployed stack
+func (s *stack) PreviousParams() []string {
+	keys := make([]string, 0)
+	for k, v := range s.rawParameters {
+		if opts, ok := resolverOptions(v); ok && len(opts) == 1 && opts["Previous"] == "true" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *stack) Dependencies() []stacker.StackRef {
+	return stackDependencies(s.rawParameters, s.region, s.profile)
 }
 
 type fetcher struct {
 	cs ConfigStore
 	ts TemplateStore
+	ps PolicyStore
 	r  ParamsResolver
+	p  Packager
 }
 
-func newFetcher(cs ConfigStore, ts TemplateStore, r ParamsResolver) *fetcher {
-	return &fetcher{cs, ts, r}
+func newFetcher(cs ConfigStore, ts TemplateStore, ps PolicyStore, r ParamsResolver, p Packager) *fetcher {
+	return &fetcher{cs, ts, ps, r, p}
 }
 
 func (f *fetcher) FetchAll() ([]stacker.Stack, error) {
@@ -51,30 +161,80 @@ func (f *fetcher) Fetch(name string) ([]stacker.Stack, error) {
 	return f.fetchTemplates(stackConfigs)
 }
 
+func (f *fetcher) FetchEnv(env string) ([]stacker.Stack, error) {
+	stackConfigs, err := f.cs.FetchEnv(env)
+	if err != nil {
+		return []stacker.Stack{}, fmt.Errorf("unable to fetch stacks in %s: %s", env, err)
+	}
+
+	return f.fetchTemplates(stackConfigs)
+}
+
+func (f *fetcher) FetchPolicy(name string) (string, error) {
+	return f.ps.Fetch(name)
+}
+
 // Fetch the templates for each stack to get a final list of params,
 // and the template body
 func (f *fetcher) fetchTemplates(stackConfigs []stackConfig) ([]stacker.Stack, error) {
 	stacks := make([]stacker.Stack, 0)
 	for _, stackConfig := range stackConfigs {
-		t, err := f.ts.Fetch(stackConfig.TemplateName)
+		t, err := f.ts.Fetch(stackConfig.TemplateName, stackConfig.TemplateVars)
 		if err != nil {
 			return stacks, fmt.Errorf("unable to fetch template %s: %s", stackConfig.TemplateName, err)
 		}
 
 		rp := make(RawParams)
-		for _, k := range t.Parameters() {
-			if v, ok := stackConfig.Parameters[k]; ok {
-				rp[k] = v
+		unused := make([]string, 0)
+		for k, v := range stackConfig.Parameters {
+			if t.Parameter(k) == nil {
+				unused = append(unused, k)
+				continue
 			}
+			rp[k] = v
+		}
+		sort.Strings(unused)
+
+		policy, err := f.fetchPolicy(stackConfig.StackPolicy)
+		if err != nil {
+			return stacks, fmt.Errorf("unable to fetch stack policy for %s: %s", stackConfig.Name, err)
 		}
 
 		s := &stack{
-			name:          stackConfig.Name,
-			region:        stackConfig.Region,
-			capabilities:  stackConfig.Capabilities,
-			templateBody:  t.Body(),
-			rawParameters: rp,
-			resolver:      f.r,
+			name:                  stackConfig.Name,
+			region:                stackConfig.Region,
+			profile:               stackConfig.Profile,
+			capabilities:          stackConfig.Capabilities,
+			tags:                  stackConfig.Tags,
+			roleARN:               stackConfig.RoleARN,
+			notificationARNs:      stackConfig.NotificationARNs,
+			terminationProtection: stackConfig.TerminationProtection,
+			stackPolicy:           policy,
+			templateName:          stackConfig.TemplateName,
+			template:              t,
+			templateBody:          t.Body(),
+			packager:              f.p,
+			rawParameters:         rp,
+			unusedParameters:      unused,
+			file:                  stackConfig.file,
+			sources:               stackConfig.sources,
+			templateVars:          stackConfig.TemplateVars,
+			resolver:              f.r,
+		}
+
+		if b := stackConfig.ArtifactBucket; b != nil {
+			s.artifactBucket = &stacker.ArtifactBucket{
+				Name:   b.Name,
+				Prefix: b.Prefix,
+				Always: b.Always,
+			}
+		}
+
+		if rc := stackConfig.RollbackConfiguration; rc != nil {
+			s.rollbackConfiguration = &stacker.RollbackConfiguration{
+				MonitoringTimeInMinutes: rc.MonitoringTime,
+				AlarmARNs:               rc.Alarms,
+			}
 		}
 
 		stacks = append(stacks, s)
@@ -82,3 +242,16 @@ func (f *fetcher) fetchTemplates(stackConfigs []stackConfig) ([]stacker.Stack, e
 
 	return stacks, nil
 }
+
+// fetchPolicy returns the body of a stack policy, which is configured either
+// with the name of a policy file or with an inline policy document
+func (f *fetcher) fetchPolicy(policy interface{}) (string, error) {
+	switch p := policy.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return f.ps.Fetch(p)
+	default:
+		return policyBody(p)
+	}
+}
@@ -0,0 +1,200 @@
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includeTag splices a partial into a YAML template, e.g.
+// `!Include partials/alarms.yml`. Paths are relative to the stacker directory.
+const includeTag = "!Include"
+
+// includer expands the includes within templates, and within the partials
+// they include
+type includer struct {
+	dir string
+}
+
+// expandIncludes replaces each include within a YAML template with the partial
+// it references. The body is returned unchanged when it has no includes.
+func expandIncludes(path string, dir string, raw []byte) ([]byte, error) {
+	if !isYAML(path) || !bytes.Contains(raw, []byte(includeTag)) {
+		return raw, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("invalid template %s: %s", path, err)
+	}
+
+	inc := &includer{dir}
+	if err := inc.expand(&root, path, []string{path}); err != nil {
+		return nil, err
+	}
+
+	body, err := encodeTemplate(path, &root)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(body), nil
+}
+
+// expand replaces the includes within a node. An included sequence is spliced
+// into a parent sequence, and an included mapping is merged into its parent
+// when included with the `<<` key. Otherwise the include is replaced by the
+// partial. chain holds the files being included, to detect cycles.
+func (inc *includer) expand(n *yaml.Node, file string, chain []string) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if err := inc.expand(c, file, chain); err != nil {
+				return err
+			}
+		}
+
+	case yaml.SequenceNode:
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for _, c := range n.Content {
+			if c.Tag != includeTag {
+				if err := inc.expand(c, file, chain); err != nil {
+					return err
+				}
+				content = append(content, c)
+				continue
+			}
+
+			partial, err := inc.include(c, file, chain)
+			if err != nil {
+				return err
+			}
+
+			if partial.Kind == yaml.SequenceNode {
+				content = append(content, partial.Content...)
+			} else {
+				content = append(content, partial)
+			}
+		}
+		n.Content = content
+
+	case yaml.MappingNode:
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if v.Tag != includeTag {
+				if err := inc.expand(v, file, chain); err != nil {
+					return err
+				}
+				content = append(content, k, v)
+				continue
+			}
+
+			partial, err := inc.include(v, file, chain)
+			if err != nil {
+				return err
+			}
+
+			if k.Tag != "!!merge" {
+				content = append(content, k, partial)
+				continue
+			}
+
+			if partial.Kind != yaml.MappingNode {
+				return fmt.Errorf("%s:%d: only a partial holding a mapping can be merged with <<", file, v.Line)
+			}
+			content = append(content, partial.Content...)
+		}
+		n.Content = content
+	}
+
+	return nil
+}
+
+// include loads the partial referenced by an include node and expands any
+// includes within it. The partial is only rendered with text/template when the
+// include passes Parameters, so that partials without any can hold
+// CloudFormation dynamic references like `{{resolve:ssm:/db/host}}`.
+func (inc *includer) include(n *yaml.Node, file string, chain []string) (*yaml.Node, error) {
+	rel, params, err := includeArgs(n)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%d: %s", file, n.Line, err)
+	}
+
+	p := filepath.Join(inc.dir, rel)
+	for _, c := range chain {
+		if filepath.Clean(c) == p {
+			return nil, fmt.Errorf("%s:%d: include cycle detected: %s -> %s", file, n.Line, strings.Join(chain, " -> "), p)
+		}
+	}
+
+	raw, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s:%d: partial %s does not exist", file, n.Line, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		tmpl, err := texttemplate.New(filepath.Base(p)).Option("missingkey=error").Parse(string(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid partial %s: %s", file, n.Line, p, err)
+		}
+
+		var body bytes.Buffer
+		if err := tmpl.Execute(&body, params); err != nil {
+			return nil, fmt.Errorf("%s:%d: unable to render partial %s: %s", file, n.Line, p, err)
+		}
+		raw = body.Bytes()
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("%s:%d: invalid partial %s: %s", file, n.Line, p, err)
+	}
+
+	partial := documentNode(&root)
+	if partial.Kind == 0 || partial.Kind == yaml.DocumentNode {
+		return nil, fmt.Errorf("%s:%d: partial %s is empty", file, n.Line, p)
+	}
+
+	if err := inc.expand(partial, p, append(chain[:len(chain):len(chain)], p)); err != nil {
+		return nil, err
+	}
+	return partial, nil
+}
+
+// includeArgs returns the partial path and parameters of an include, which is
+// either a path, or a mapping with a Path and optional Parameters. Parameters
+// are nil when the include doesn't pass any.
+func includeArgs(n *yaml.Node) (string, map[string]interface{}, error) {
+	var params map[string]interface{}
+
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Value != "" {
+			return n.Value, nil, nil
+		}
+	case yaml.MappingNode:
+		path := mappingValue(n, "Path")
+		if path == nil || path.Kind != yaml.ScalarNode || path.Value == "" {
+			break
+		}
+
+		if p := mappingValue(n, "Parameters"); p != nil {
+			params = make(map[string]interface{})
+			if err := p.Decode(&params); err != nil || p.Kind != yaml.MappingNode {
+				return "", nil, fmt.Errorf("%s expects Parameters to be a mapping", includeTag)
+			}
+		}
+		return path.Value, params, nil
+	}
+
+	return "", nil, fmt.Errorf("%s expects a partial path, or a mapping with a Path and optional Parameters", includeTag)
+}
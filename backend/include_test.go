@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const TestStackerDir = "../test/stacker"
+
+func TestExpandIncludes(t *testing.T) {
+	cases := []struct {
+		path     string
+		body     string
+		expected string
+		err      string
+	}{
+		{
+			"Topic.yml",
+			"Resources:\n  Topic: !Include partials/topic.yml\n",
+			"Resources:\n  Topic:\n    Type: AWS::SNS::Topic\n",
+			"",
+		},
+		{
+			"Statements.yml",
+			"Statement:\n  - Effect: Deny\n  - !Include partials/statements.json\n",
+			"Statement:\n  - Effect: Deny\n  - {\"Effect\": \"Allow\", \"Action\": [\"logs:CreateLogStream\", \"logs:PutLogEvents\"], \"Resource\": \"*\"}\n",
+			"",
+		},
+		{
+			"Database.yml",
+			"Resources:\n  Database: !Include partials/database.yml\n",
+			"Resources:\n  Database:\n    Type: AWS::RDS::DBInstance\n    Properties:\n      Engine: postgres\n" +
+				"      MasterUsername: '{{resolve:ssm:/db/username}}'\n" +
+				"      MasterUserPassword: '{{resolve:secretsmanager:db:SecretString:password}}'\n",
+			"",
+		},
+		{
+			"Unchanged.json",
+			`{"Resources": {}}`,
+			`{"Resources": {}}`,
+			"",
+		},
+		{
+			"Alarm.yml",
+			"Resources:\n  Alarm: !Include\n    Path: partials/alarm.yml\n    Parameters:\n      Metric: CPUUtilization\n",
+			"",
+			`Alarm.yml:2: unable to render partial ../test/stacker/partials/alarm.yml: template: alarm.yml:3:23: executing "alarm.yml" at <.Description>: map has no entry for key "Description"`,
+		},
+		{
+			"Merge.yml",
+			"Resources:\n  <<: !Include partials/statements.json\n",
+			"",
+			"Merge.yml:2: only a partial holding a mapping can be merged with <<",
+		},
+		{
+			"Arguments.yml",
+			"Resources:\n  Topic: !Include\n    Parameters: {}\n",
+			"",
+			"Arguments.yml:2: !Include expects a partial path, or a mapping with a Path and optional Parameters",
+		},
+	}
+
+	for _, c := range cases {
+		body, err := expandIncludes(c.path, TestStackerDir, []byte(c.body))
+
+		if c.err != "" {
+			assert.EqualError(t, err, c.err)
+			continue
+		}
+
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, string(body))
+	}
+}
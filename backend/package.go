@@ -0,0 +1,395 @@
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/eyeamera/stacker-cli/client"
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+// artifactFormat describes how the location of an uploaded artifact replaces
+// the local path within a template
+type artifactFormat int
+
+const (
+	s3URI       artifactFormat = iota // s3://bucket/key
+	s3Object                          // S3Bucket and S3Key
+	bucketKey                         // Bucket and Key
+	templateURL                       // https url of a packaged nested template
+)
+
+// artifactProperty is a resource property which may reference a local file or
+// directory. Directories are zipped, as are files other than zips when zip is
+// set.
+type artifactProperty struct {
+	name   string
+	zip    bool
+	format artifactFormat
+}
+
+// artifactProperties are the properties which are packaged, by resource type
+var artifactProperties = map[string]artifactProperty{
+	"AWS::Lambda::Function":                     {"Code", true, s3Object},
+	"AWS::Lambda::LayerVersion":                 {"Content", true, s3Object},
+	"AWS::Serverless::Function":                 {"CodeUri", true, s3URI},
+	"AWS::Serverless::LayerVersion":             {"ContentUri", true, s3URI},
+	"AWS::Serverless::Api":                      {"DefinitionUri", false, s3URI},
+	"AWS::Serverless::StateMachine":             {"DefinitionUri", false, s3URI},
+	"AWS::ApiGateway::RestApi":                  {"BodyS3Location", false, bucketKey},
+	"AWS::StepFunctions::StateMachine":          {"DefinitionS3Location", false, bucketKey},
+	"AWS::ElasticBeanstalk::ApplicationVersion": {"SourceBundle", true, s3Object},
+	"AWS::CloudFormation::Stack":                {"TemplateURL", false, templateURL},
+}
+
+type Packager interface {
+	Package(t Template, s stacker.Stack) (string, error)
+}
+
+// packager uploads the local artifacts referenced by templates to the stack's
+// artifact bucket, like `aws cloudformation package`
+type packager struct {
+	dir         string
+	newUploader func(region, profile string) (client.Uploader, error)
+}
+
+func newPackager(dir string, newUploader func(region, profile string) (client.Uploader, error)) *packager {
+	return &packager{dir, newUploader}
+}
+
+// Package returns the template's body with each local artifact replaced by
+// its uploaded location. Nested templates are packaged before they're
+// uploaded. The body is returned unchanged when it references no local
+// artifacts.
+func (p *packager) Package(t Template, s stacker.Stack) (string, error) {
+	pkg := &packaging{p: p, stack: s}
+	return pkg.template(t.Path(), []byte(t.Body()), nil)
+}
+
+// packaging holds the state of packaging a single stack's template
+type packaging struct {
+	p        *packager
+	stack    stacker.Stack
+	uploader client.Uploader
+}
+
+// localArtifact is a property node referencing a local path
+type localArtifact struct {
+	resource string
+	property artifactProperty
+	node     *yaml.Node
+}
+
+func (pkg *packaging) template(file string, body []byte, parents []string) (string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(body, &root); err != nil {
+		return "", fmt.Errorf("invalid template %s: %s", file, err)
+	}
+
+	artifacts := localArtifacts(&root)
+	if len(artifacts) == 0 {
+		return string(body), nil
+	}
+
+	bucket := pkg.stack.ArtifactBucket()
+	if bucket == nil {
+		return "", fmt.Errorf(
+			"template %s references local artifacts for resource `%s`; configure an artifact_bucket to upload them",
+			file, artifacts[0].resource,
+		)
+	}
+
+	chain := append(append([]string{}, parents...), file)
+
+	for _, a := range artifacts {
+		local := filepath.Join(filepath.Dir(file), a.node.Value)
+
+		var (
+			content []byte
+			ext     string
+			err     error
+		)
+		if a.property.format == templateURL {
+			content, ext, err = pkg.nestedTemplate(local, chain)
+		} else {
+			content, ext, err = readArtifact(local, a.property.zip)
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to package %s of resource `%s` in %s: %s", a.property.name, a.resource, file, err)
+		}
+
+		key := artifactKey(bucket.Prefix, content, ext)
+		url, err := pkg.upload(bucket.Name, key, content)
+		if err != nil {
+			return "", err
+		}
+
+		if err := replaceArtifact(a, bucket.Name, key, url); err != nil {
+			return "", err
+		}
+	}
+
+	return encodeTemplate(file, &root)
+}
+
+// nestedTemplate packages a template referenced by a nested stack
+func (pkg *packaging) nestedTemplate(file string, parents []string) ([]byte, string, error) {
+	for _, parent := range parents {
+		if filepath.Clean(parent) == filepath.Clean(file) {
+			return nil, "", fmt.Errorf("template %s is nested within itself via %s", file, strings.Join(parents, " -> "))
+		}
+	}
+
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if raw, err = expandIncludes(file, pkg.p.dir, raw); err != nil {
+		return nil, "", err
+	}
+
+	body, err := pkg.template(file, raw, parents)
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(body), ".template", nil
+}
+
+func (pkg *packaging) upload(bucket, key string, body []byte) (string, error) {
+	if pkg.uploader == nil {
+		u, err := pkg.p.newUploader(pkg.stack.Region(), pkg.stack.Profile())
+		if err != nil {
+			return "", fmt.Errorf("unable to create uploader: %s", err)
+		}
+		pkg.uploader = u
+	}
+
+	url, err := pkg.uploader.Upload(bucket, key, body)
+	if err != nil {
+		return "", fmt.Errorf("unable to upload artifact: %s", err)
+	}
+	return url, nil
+}
+
+// localArtifacts returns the packaged properties of a template's resources
+// which reference local paths, in the order they're declared
+func localArtifacts(root *yaml.Node) []localArtifact {
+	artifacts := make([]localArtifact, 0)
+
+	resources := mappingValue(documentNode(root), "Resources")
+	if resources == nil || resources.Kind != yaml.MappingNode {
+		return artifacts
+	}
+
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		name, resource := resources.Content[i].Value, resources.Content[i+1]
+
+		t := mappingValue(resource, "Type")
+		if t == nil {
+			continue
+		}
+
+		property, ok := artifactProperties[t.Value]
+		if !ok {
+			continue
+		}
+
+		n := mappingValue(mappingValue(resource, "Properties"), property.name)
+		if n == nil || !isLocalPath(n) {
+			continue
+		}
+
+		artifacts = append(artifacts, localArtifact{name, property, n})
+	}
+
+	return artifacts
+}
+
+// isLocalPath returns whether a node is a plain string which isn't an S3 or
+// http location
+func isLocalPath(n *yaml.Node) bool {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!str" || n.Value == "" {
+		return false
+	}
+
+	for _, prefix := range []string{"s3://", "http://", "https://"} {
+		if strings.HasPrefix(n.Value, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceArtifact replaces a local path with the location it was uploaded to
+func replaceArtifact(a localArtifact, bucket, key, url string) error {
+	switch a.property.format {
+	case s3URI:
+		return a.node.Encode(fmt.Sprintf("s3://%s/%s", bucket, key))
+	case s3Object:
+		return a.node.Encode(map[string]string{"S3Bucket": bucket, "S3Key": key})
+	case bucketKey:
+		return a.node.Encode(map[string]string{"Bucket": bucket, "Key": key})
+	default:
+		return a.node.Encode(url)
+	}
+}
+
+// encodeTemplate encodes a packaged template in the format of the original
+func encodeTemplate(file string, root *yaml.Node) (string, error) {
+	if !isYAML(file) {
+		var doc interface{}
+		if err := root.Decode(&doc); err != nil {
+			return "", err
+		}
+
+		body, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(body), nil
+	}
+
+	var body bytes.Buffer
+	enc := yaml.NewEncoder(&body)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+// readArtifact returns the content to upload for a local path, and the
+// extension of the key it's uploaded to
+func readArtifact(file string, zipped bool) ([]byte, string, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if info.IsDir() {
+		if !zipped {
+			return nil, "", fmt.Errorf("%s is a directory", file)
+		}
+		content, err := zipDir(file)
+		return content, ".zip", err
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ext := filepath.Ext(file)
+	if zipped && ext != ".zip" && ext != ".jar" {
+		content, err = zipFiles([]zipEntry{{filepath.Base(file), info.Mode(), content}})
+		return content, ".zip", err
+	}
+
+	return content, ext, nil
+}
+
+type zipEntry struct {
+	name    string
+	mode    os.FileMode
+	content []byte
+}
+
+// zipDir zips the files within a directory
+func zipDir(dir string) ([]byte, error) {
+	entries := make([]zipEntry, 0)
+
+	// Files are walked in lexical order, so the archive is always built in
+	// the same order
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, zipEntry{filepath.ToSlash(rel), info.Mode(), content})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return zipFiles(entries)
+}
+
+// zipFiles builds a zip archive. Modification times are left out, so that the
+// same files always produce the same archive, and so the same key.
+func zipFiles(entries []zipEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		h.SetMode(e.mode)
+
+		f, err := w.CreateHeader(h)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(e.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// artifactKey returns an S3 key addressing an artifact by its content
+func artifactKey(prefix string, content []byte, ext string) string {
+	sum := sha256.Sum256(content)
+	return path.Join(prefix, "artifacts", hex.EncodeToString(sum[:])+ext)
+}
+
+// documentNode returns the root value of a parsed document
+func documentNode(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		return n.Content[0]
+	}
+	return n
+}
+
+// mappingValue returns the value of a key within a mapping node, or nil when
+// the node isn't a mapping or the key doesn't exist
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
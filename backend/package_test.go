@@ -0,0 +1,189 @@
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/eyeamera/stacker-cli/client"
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+const TestPackagingDir = "../test/packaging/templates"
+
+// fakeUploader stores uploads in memory, by key
+type fakeUploader struct {
+	region  string
+	uploads map[string][]byte
+}
+
+func (u *fakeUploader) Upload(bucket string, key string, body []byte) (string, error) {
+	u.uploads[key] = body
+	return "https://" + bucket + ".s3." + u.region + ".amazonaws.com/" + key, nil
+}
+
+func newFakePackager() (*packager, *fakeUploader) {
+	u := &fakeUploader{uploads: make(map[string][]byte)}
+	return newPackager("../test/packaging", func(region, profile string) (client.Uploader, error) {
+		u.region = region
+		return u, nil
+	}), u
+}
+
+func zipNames(t *testing.T, content []byte) []string {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	assert.Nil(t, err)
+
+	names := make([]string, len(r.File))
+	for i, f := range r.File {
+		names[i] = f.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPackagerPackage(t *testing.T) {
+	p, u := newFakePackager()
+	s := &stack{
+		region:         "us-west-2",
+		artifactBucket: &stacker.ArtifactBucket{Name: "artifacts", Prefix: "stacker"},
+	}
+
+	tmpl, err := parseTemplate(TestPackagingDir+"/Functions.yml", "../test/packaging")
+	assert.Nil(t, err)
+
+	body, err := p.Package(tmpl, s)
+	assert.Nil(t, err)
+
+	api, _ := ioutil.ReadFile("../test/packaging/definitions/api.yml")
+	queues, _ := ioutil.ReadFile(TestPackagingDir + "/Queues.yml")
+
+	var (
+		codeKey   = artifactKey("stacker", u.uploads[findKey(u.uploads, ".zip")], ".zip")
+		apiKey    = artifactKey("stacker", api, ".yml")
+		queuesKey = artifactKey("stacker", queues, ".template")
+	)
+
+	assert.Len(t, u.uploads, 3)
+	assert.Equal(t, []string{"index.js", "lib/util.js"}, zipNames(t, u.uploads[codeKey]))
+	assert.Equal(t, api, u.uploads[apiKey])
+	assert.Equal(t, queues, u.uploads[queuesKey])
+
+	assert.Equal(t, `AWSTemplateFormatVersion: '2010-09-09'
+Parameters:
+  Name:
+    Type: String
+Resources:
+  Hello:
+    Type: AWS::Lambda::Function
+    Properties:
+      FunctionName: !Ref Name
+      Handler: index.handler
+      Runtime: nodejs18.x
+      Code:
+        S3Bucket: artifacts
+        S3Key: `+codeKey+`
+  Remote:
+    Type: AWS::Lambda::Function
+    Properties:
+      Handler: index.handler
+      Runtime: nodejs18.x
+      Code:
+        S3Bucket: shared-artifacts
+        S3Key: remote.zip
+  Api:
+    Type: AWS::ApiGateway::RestApi
+    Properties:
+      BodyS3Location:
+        Bucket: artifacts
+        Key: `+apiKey+`
+  Queues:
+    Type: AWS::CloudFormation::Stack
+    Properties:
+      TemplateURL: https://artifacts.s3.us-west-2.amazonaws.com/`+queuesKey+`
+`, body)
+
+	// Zips are built the same way each time, so their key doesn't change
+	_, err = p.Package(tmpl, s)
+	assert.Nil(t, err)
+	assert.Len(t, u.uploads, 3)
+}
+
+func TestPackagerPackageJSON(t *testing.T) {
+	p, u := newFakePackager()
+	s := &stack{
+		region:         "us-east-1",
+		artifactBucket: &stacker.ArtifactBucket{Name: "artifacts"},
+	}
+
+	tmpl, err := parseTemplate(TestPackagingDir+"/Serverless.json", "../test/packaging")
+	assert.Nil(t, err)
+
+	body, err := p.Package(tmpl, s)
+	assert.Nil(t, err)
+
+	key := findKey(u.uploads, ".zip")
+	assert.Equal(t, []string{"index.js"}, zipNames(t, u.uploads[key]))
+	assert.Equal(t, `{
+  "AWSTemplateFormatVersion": "2010-09-09",
+  "Resources": {
+    "Hello": {
+      "Properties": {
+        "CodeUri": "s3://artifacts/`+key+`",
+        "Handler": "index.handler",
+        "Runtime": "nodejs18.x"
+      },
+      "Type": "AWS::Serverless::Function"
+    }
+  },
+  "Transform": "AWS::Serverless-2016-10-31"
+}`, body)
+}
+
+func TestPackagerPackageUnchanged(t *testing.T) {
+	p, u := newFakePackager()
+
+	tmpl, err := parseTemplate(TestPackagingDir+"/Remote.json", "../test/packaging")
+	assert.Nil(t, err)
+
+	body, err := p.Package(tmpl, &stack{region: "us-east-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, tmpl.Body(), body)
+	assert.Empty(t, u.uploads)
+}
+
+func TestPackagerPackageErrors(t *testing.T) {
+	p, _ := newFakePackager()
+	bucket := &stacker.ArtifactBucket{Name: "artifacts"}
+
+	functions, _ := parseTemplate(TestPackagingDir+"/Functions.yml", "../test/packaging")
+	_, err := p.Package(functions, &stack{region: "us-east-1"})
+	assert.EqualError(t, err, "template ../test/packaging/templates/Functions.yml references local artifacts for resource `Hello`; configure an artifact_bucket to upload them")
+
+	parent, _ := parseTemplate(TestPackagingDir+"/Parent.yml", "../test/packaging")
+	_, err = p.Package(parent, &stack{region: "us-east-1", artifactBucket: bucket})
+	assert.EqualError(t, err, "unable to package TemplateURL of resource `Child` in ../test/packaging/templates/Parent.yml: "+
+		"unable to package TemplateURL of resource `Parent` in ../test/packaging/templates/Child.yml: "+
+		"template ../test/packaging/templates/Parent.yml is nested within itself via ../test/packaging/templates/Parent.yml -> ../test/packaging/templates/Child.yml")
+
+	missing := &template{
+		path: TestPackagingDir + "/Missing.yml",
+		body: "Resources:\n  Hello:\n    Type: AWS::Serverless::Function\n    Properties:\n      CodeUri: ../functions/missing\n",
+	}
+	_, err = p.Package(missing, &stack{region: "us-east-1", artifactBucket: bucket})
+	assert.EqualError(t, err, "unable to package CodeUri of resource `Hello` in ../test/packaging/templates/Missing.yml: stat ../test/packaging/functions/missing: no such file or directory")
+}
+
+func findKey(uploads map[string][]byte, ext string) string {
+	for k := range uploads {
+		if strings.HasSuffix(k, ext) {
+			return k
+		}
+	}
+	return ""
+}
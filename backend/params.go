@@ -3,6 +3,9 @@ package backend
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 
@@ -14,11 +17,13 @@ type stackParam struct {
 	key         string
 	value       string
 	usePrevious bool
+	sensitive   bool
 }
 
 func (sp *stackParam) Key() string       { return sp.key }
 func (sp *stackParam) Value() string     { return sp.value }
 func (sp *stackParam) UsePrevious() bool { return sp.usePrevious }
+func (sp *stackParam) Sensitive() bool   { return sp.sensitive }
 
 type RawParams map[string]interface{}
 
@@ -26,35 +31,97 @@ type Resolver func(key string, param interface{}, stack stacker.Stack) (stacker.
 
 type ParamsResolver interface {
 	Resolve(rp RawParams, stack stacker.Stack) ([]stacker.StackParam, error)
+	Sensitive(v interface{}) bool
 }
 
 type paramsResolver struct {
 	resolvers map[string]Resolver
+	sensitive map[string]bool
 }
 
+// NewParamsResolver returns a resolver with only the `Sub` resolver
+// registered, which interpolates the other registered resolvers
 func NewParamsResolver() *paramsResolver {
-	return &paramsResolver{
+	pr := &paramsResolver{
 		resolvers: make(map[string]Resolver),
+		sensitive: make(map[string]bool),
 	}
+	pr.Add("Sub", pr.resolveSub)
+	return pr
 }
 
 func (pr *paramsResolver) Add(key string, r Resolver) {
 	pr.resolvers[key] = r
 }
 
+// AddSensitive registers a resolver which may provide secrets, so that the
+// parameters using it can be masked without resolving them
+func (pr *paramsResolver) AddSensitive(key string, r Resolver) {
+	pr.Add(key, r)
+	pr.sensitive[key] = true
+}
+
+// Resolve resolves every parameter concurrently, returning the parameters
+// sorted by key. When parameters fail to resolve, the errors of every failed
+// parameter are returned in key order.
 func (pr *paramsResolver) Resolve(rp RawParams, stack stacker.Stack) ([]stacker.StackParam, error) {
-	sp := make([]stacker.StackParam, 0)
-	for k, v := range rp {
-		r, err := pr.resolve(k, v, stack)
-		if err != nil {
-			return nil, errors.Wrapf(err, "an error occured resolving %s", k)
-		}
+	keys := make([]string, 0, len(rp))
+	for k := range rp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var (
+		wg   sync.WaitGroup
+		sp   = make([]stacker.StackParam, len(keys))
+		errs = make([]error, len(keys))
+	)
 
-		sp = append(sp, r)
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, k string) {
+			defer wg.Done()
+			r, err := pr.resolve(k, rp[k], stack)
+			if err != nil {
+				errs[i] = errors.Wrapf(err, "an error occured resolving %s", k)
+				return
+			}
+			sp[i] = r
+		}(i, k)
+	}
+	wg.Wait()
+
+	if err := combineErrors(errs); err != nil {
+		return nil, err
 	}
 	return sp, nil
 }
 
+// combineErrors returns the single non-nil error within errs, or an error
+// listing each of them in order when there are several
+func combineErrors(errs []error) error {
+	msgs := make([]string, 0)
+	var first error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+		}
+		msgs = append(msgs, err.Error())
+	}
+
+	switch len(msgs) {
+	case 0:
+		return nil
+	case 1:
+		return first
+	default:
+		return fmt.Errorf("%d errors occured:\n  %s", len(msgs), strings.Join(msgs, "\n  "))
+	}
+}
+
 func (pr *paramsResolver) resolve(k string, v interface{}, stack stacker.Stack) (stacker.StackParam, error) {
 	original := reflect.ValueOf(v)
 	switch original.Kind() {
@@ -72,7 +139,10 @@ func (pr *paramsResolver) resolve(k string, v interface{}, stack stacker.Stack)
 
 		return resolver(k, original.MapIndex(keys[0]).Interface(), stack)
 	case reflect.Slice:
-		var s string
+		var (
+			s         string
+			sensitive bool
+		)
 		for i := 0; i < original.Len(); i++ {
 			if i > 0 {
 				s += ","
@@ -81,9 +151,170 @@ func (pr *paramsResolver) resolve(k string, v interface{}, stack stacker.Stack)
 			if err != nil {
 				return nil, err
 			}
+			if v.UsePrevious() {
+				return nil, errors.New("a previous value cannot be used within a list")
+			}
 			s += v.Value()
+			sensitive = sensitive || v.Sensitive()
 		}
-		return &stackParam{key: k, value: s}, nil
+		return &stackParam{key: k, value: s, sensitive: sensitive}, nil
 	}
 	return &stackParam{key: k, value: fmt.Sprint(v)}, nil
 }
+
+// check reports references to resolvers which aren't registered, without
+// resolving the parameter
+func (pr *paramsResolver) check(v interface{}) error {
+	original := reflect.ValueOf(v)
+	switch original.Kind() {
+	case reflect.Map:
+		keys := original.MapKeys()
+		if len(keys) != 1 {
+			return errors.New("expected a map with a single resolver key")
+		}
+
+		key := fmt.Sprint(keys[0])
+		if _, ok := pr.resolvers[key]; !ok {
+			return fmt.Errorf("unknown resolver `%s`", key)
+		}
+
+		if key != "Sub" {
+			return nil
+		}
+
+		parts, err := parseSub(fmt.Sprint(original.MapIndex(keys[0]).Interface()))
+		if err != nil {
+			return err
+		}
+		for _, p := range parts {
+			if _, ok := pr.resolvers[p.resolver]; p.resolver != "" && !ok {
+				return fmt.Errorf("unknown resolver `%s` in substitution ${%s:%s}", p.resolver, p.resolver, p.arg)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < original.Len(); i++ {
+			if err := pr.check(original.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Sensitive reports whether a raw parameter uses a sensitive resolver, either
+// directly, within a list or substituted into a `Sub` string
+func (pr *paramsResolver) Sensitive(v interface{}) bool {
+	original := reflect.ValueOf(v)
+	switch original.Kind() {
+	case reflect.Map:
+		for _, k := range original.MapKeys() {
+			key := fmt.Sprint(k)
+			if pr.sensitive[key] {
+				return true
+			}
+
+			if key != "Sub" {
+				continue
+			}
+
+			parts, err := parseSub(fmt.Sprint(original.MapIndex(k).Interface()))
+			if err != nil {
+				continue
+			}
+			for _, p := range parts {
+				if pr.sensitive[p.resolver] {
+					return true
+				}
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < original.Len(); i++ {
+			if pr.Sensitive(original.Index(i).Interface()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveSub interpolates the values of other resolvers into a string. Each
+// ${Resolver:arg} is replaced by the value resolving arg with the named
+// resolver would produce, while ${!text} is replaced by a literal ${text}.
+//
+// Example Usage:
+//
+//   parameters:
+//     BucketArn:
+//       Sub: "arn:aws:s3:::${Stack:Storage.BucketName}/${Env:STAGE}/*"
+func (pr *paramsResolver) resolveSub(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
+	parts, err := parseSub(fmt.Sprint(param))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		value     string
+		sensitive bool
+	)
+	for _, p := range parts {
+		if p.resolver == "" {
+			value += p.literal
+			continue
+		}
+
+		r, err := pr.resolve(key, map[string]interface{}{p.resolver: p.arg}, stack)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to substitute ${%s:%s}", p.resolver, p.arg)
+		}
+
+		value += r.Value()
+		sensitive = sensitive || r.Sensitive()
+	}
+
+	return &stackParam{key: key, value: value, sensitive: sensitive}, nil
+}
+
+// subPart is either literal text, or a resolver and its argument
+type subPart struct {
+	literal  string
+	resolver string
+	arg      string
+}
+
+// parseSub splits a string into literal text and ${Resolver:arg} substitutions
+func parseSub(s string) ([]subPart, error) {
+	parts := make([]subPart, 0)
+
+	for s != "" {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			parts = append(parts, subPart{literal: s})
+			break
+		}
+
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated substitution in `%s`", s)
+		}
+		end += start
+
+		if start > 0 {
+			parts = append(parts, subPart{literal: s[:start]})
+		}
+
+		sub := s[start+2 : end]
+		switch {
+		case strings.HasPrefix(sub, "!"):
+			parts = append(parts, subPart{literal: "${" + sub[1:] + "}"})
+		case strings.Contains(sub, ":"):
+			kv := strings.SplitN(sub, ":", 2)
+			parts = append(parts, subPart{resolver: kv[0], arg: kv[1]})
+		default:
+			return nil, fmt.Errorf("expected substitution `${%s}` in format ${<resolver>:<arg>}", sub)
+		}
+
+		s = s[end+1:]
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	policyExtensions = []string{".json", ".yaml", ".yml"}
+)
+
+type PolicyStore interface {
+	Fetch(name string) (string, error)
+}
+
+// policyStore loads stack policy documents from the policies directory. YAML
+// documents are converted to JSON, the only format cloudformation accepts.
+type policyStore struct {
+	path string
+	d    map[string]string
+}
+
+func newPolicyStore(path string) *policyStore {
+	return &policyStore{
+		path: path,
+		d:    make(map[string]string),
+	}
+}
+
+func (ps *policyStore) Fetch(name string) (string, error) {
+	if p, ok := ps.d[name]; ok {
+		return p, nil
+	}
+
+	// check known extensions for policy file
+	for _, ext := range policyExtensions {
+		p := path.Join(ps.path, name+ext)
+
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+
+		policy, err := parsePolicy(p)
+		if err != nil {
+			return "", err
+		}
+
+		ps.d[name] = policy
+
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("unable to locate policy %s", name)
+}
+
+func parsePolicy(path string) (string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var doc interface{}
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		err = yaml.Unmarshal(raw, &doc)
+	} else {
+		err = json.Unmarshal(raw, &doc)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid policy %s: %s", path, err)
+	}
+
+	policy, err := policyBody(doc)
+	if err != nil {
+		return "", fmt.Errorf("invalid policy %s: %s", path, err)
+	}
+
+	return policy, nil
+}
+
+// policyBody encodes a policy document as indented JSON
+func policyBody(doc interface{}) (string, error) {
+	body, err := json.MarshalIndent(jsonValue(doc), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// jsonValue converts the maps decoded from YAML, which are keyed by
+// interface{}, into maps which can be encoded as JSON
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprint(k)] = jsonValue(v)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = jsonValue(v)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, v := range t {
+			s[i] = jsonValue(v)
+		}
+		return s
+	default:
+		return v
+	}
+}
@@ -0,0 +1,38 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const TestPoliciesDir = "../test/stacker/policies"
+
+func TestPolicyStoreFetch(t *testing.T) {
+	ps := newPolicyStore(TestPoliciesDir)
+
+	policy, err := ps.Fetch("AllowReplace")
+
+	expected := `{
+  "Statement": [
+    {
+      "Action": "Update:*",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Resource": "*"
+    }
+  ]
+}`
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, policy)
+
+	policy, err = ps.Fetch("Database")
+
+	assert.Nil(t, err)
+	assert.Contains(t, policy, `"Resource": "LogicalResourceId/Database"`)
+
+	_, err = ps.Fetch("Missing")
+
+	assert.EqualError(t, err, "unable to locate policy Missing")
+}
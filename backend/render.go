@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/eyeamera/stacker-cli/client"
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+// Render returns the effective configuration of a fetched stack. Parameters
+// are resolved unless offline is set.
+func (f *fetcher) Render(st stacker.Stack, offline bool) (*stacker.RenderedStack, error) {
+	s, ok := st.(*stack)
+	if !ok {
+		return nil, fmt.Errorf("unable to render stack %s: not a local stack", st.Name())
+	}
+
+	r, err := render(s, offline)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render stack %s: %s", s.name, err)
+	}
+	return r, nil
+}
+
+func render(s *stack, offline bool) (*stacker.RenderedStack, error) {
+	r := &stacker.RenderedStack{
+		Name:                  s.name,
+		File:                  s.file,
+		Region:                s.region,
+		Profile:               s.profile,
+		Template:              s.template.Path(),
+		Capabilities:          s.capabilities,
+		RoleARN:               s.roleARN,
+		NotificationARNs:      s.notificationARNs,
+		RollbackConfiguration: s.rollbackConfiguration,
+		TerminationProtection: s.terminationProtection,
+		ArtifactBucket:        s.artifactBucket,
+		Tags:                  s.tags,
+		Parameters:            make(map[string]stacker.RenderedParam),
+		UnusedParameters:      s.unusedParameters,
+	}
+
+	// Policies are held as JSON, so are decoded to render as a document
+	if s.stackPolicy != "" {
+		if err := json.Unmarshal([]byte(s.stackPolicy), &r.StackPolicy); err != nil {
+			return nil, fmt.Errorf("invalid stack policy: %s", err)
+		}
+	}
+
+	if len(s.templateVars) > 0 {
+		r.TemplateVars = jsonValue(s.templateVars).(map[string]interface{})
+	}
+
+	if offline {
+		for k, v := range s.rawParameters {
+			r.Parameters[k] = stacker.RenderedParam{Value: jsonValue(v), Source: s.sources[k]}
+		}
+		return r, nil
+	}
+
+	params, err := s.Params()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range params {
+		rp := stacker.RenderedParam{Source: s.sources[p.Key()]}
+		switch {
+		case p.UsePrevious():
+			rp.Previous = true
+		case p.Sensitive():
+			rp.Value, rp.Sensitive = client.SensitiveValueMask, true
+		default:
+			rp.Value = p.Value()
+		}
+		r.Parameters[p.Key()] = rp
+	}
+
+	return r, nil
+}
@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+func TestFetcherRender(t *testing.T) {
+	pr := NewParamsResolver()
+	pr.Add("Previous", ResolvePrevious)
+
+	cs := &fakeConfigStore{}
+	cs.On("Fetch", "Orders").Return([]stackConfig{
+		{
+			Name:         "Orders",
+			Region:       "us-east-1",
+			TemplateName: "Database",
+			Parameters: map[string]interface{}{
+				"Name":      "orders",
+				"Storage":   100,
+				"Password":  map[interface{}]interface{}{"Previous": true},
+				"SubnetIds": []interface{}{"a", "b"},
+				"Unused":    "x",
+			},
+			TemplateVars: map[string]interface{}{
+				"Replicas": []interface{}{map[interface{}]interface{}{"Zone": "us-east-1a"}},
+			},
+			stackOptions: stackOptions{
+				RollbackConfiguration: &rollbackConfig{MonitoringTime: 10, Alarms: []string{"arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors"}},
+				StackPolicy:           "AllowReplace",
+				ArtifactBucket:        &artifactBucket{Name: "artifacts", Prefix: "stacker"},
+			},
+			file: "environments/production/orders.yml",
+			sources: map[string]string{
+				"Name":      "environments/production/orders.yml",
+				"Storage":   "environments/production.yml",
+				"Password":  "environments/production/orders.yml",
+				"SubnetIds": "environments/production.yml",
+				"Unused":    "environments/production.yml",
+			},
+		},
+	}, nil)
+
+	f := newFetcher(cs, newTemplateStore(TestTemplatesDir), newPolicyStore(TestPoliciesDir), pr, nil)
+
+	stacks, err := f.Fetch("Orders")
+	assert.Nil(t, err)
+	assert.Len(t, stacks, 1)
+
+	rendered, err := f.Render(stacks[0], false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &stacker.RenderedStack{
+		Name:     "Orders",
+		File:     "environments/production/orders.yml",
+		Region:   "us-east-1",
+		Template: TestTemplatesDir + "/Database.yml",
+		RollbackConfiguration: &stacker.RollbackConfiguration{
+			MonitoringTimeInMinutes: 10,
+			AlarmARNs:               []string{"arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors"},
+		},
+		StackPolicy: map[string]interface{}{
+			"Statement": []interface{}{
+				map[string]interface{}{"Effect": "Allow", "Action": "Update:*", "Principal": "*", "Resource": "*"},
+			},
+		},
+		ArtifactBucket: &stacker.ArtifactBucket{Name: "artifacts", Prefix: "stacker"},
+		TemplateVars: map[string]interface{}{
+			"Replicas": []interface{}{map[string]interface{}{"Zone": "us-east-1a"}},
+		},
+		Parameters: map[string]stacker.RenderedParam{
+			"Name":      {Value: "orders", Source: "environments/production/orders.yml"},
+			"Storage":   {Value: "100", Source: "environments/production.yml"},
+			"Password":  {Previous: true, Source: "environments/production/orders.yml"},
+			"SubnetIds": {Value: "a,b", Source: "environments/production.yml"},
+		},
+		UnusedParameters: []string{"Unused"},
+	}, rendered)
+
+	rendered, err = f.Render(stacks[0], true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]stacker.RenderedParam{
+		"Name":      {Value: "orders", Source: "environments/production/orders.yml"},
+		"Storage":   {Value: 100, Source: "environments/production.yml"},
+		"Password":  {Value: map[string]interface{}{"Previous": true}, Source: "environments/production/orders.yml"},
+		"SubnetIds": {Value: []interface{}{"a", "b"}, Source: "environments/production.yml"},
+	}, rendered.Parameters)
+}
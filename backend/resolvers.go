@@ -1,47 +1,299 @@
 package backend
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/pkg/errors"
 
 	"github.com/eyeamera/stacker-cli/client"
 	"github.com/eyeamera/stacker-cli/stacker"
 )
 
-// ResolveStackOutput provides a mechanism to lookup an output from a stack within
-// the same region
+// NewStackOutputResolver returns a resolver which looks up an output from an
+// existing stack. Stacks are looked up within the same region and account as
+// the stack unless another region, or a named profile from the shared aws
+// config, is provided.
+//
+// A client is created once for each region and profile. Once a stack has been
+// found its outputs are cached by the resolver for the rest of the command,
+// while failed lookups and stacks which don't exist are described again the
+// next time they're referenced. Commands which deploy stacks resolve
+// parameters in dependency order, so the outputs of a stack within the stacker
+// directory are only cached once it has been deployed.
 //
 // Example Usage:
 //
 //   parameters:
 //     VpcID:
 //       Stack: Foo-VPC.VpcID
+//     CertificateArn:
+//       Stack:
+//         stack: Global-Certificates
+//         output: CertificateArn
+//         region: us-east-1
+//         profile: shared
 //
 // where 'Foo-VPC' is the stack name, and 'VpcId' is the stack output
-func ResolveStackOutput(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
-	s := strings.SplitN(fmt.Sprint(param), ".", 2)
-	if len(s) != 2 {
-		return nil, fmt.Errorf("expected to receive input in format <stack>.<output>")
+func NewStackOutputResolver(newClient func(region, profile string) (client.CloudformationClient, error)) Resolver {
+	type lookup struct {
+		mu sync.Mutex
+		si *client.StackInfo
 	}
 
-	stackName, outputName := s[0], s[1]
-	client := client.New(client.NewCloudformationClient(stack.Region()))
-	si, err := client.Get(stackName)
-	if err != nil {
-		return nil, errors.Wrapf(err, "unable to fetch stack `%s`", stackName)
+	var (
+		mu      sync.Mutex
+		clients = make(map[string]*client.Client)
+		lookups = make(map[string]*lookup)
+	)
+
+	// describe fetches a stack, sharing the result between every reference
+	// to the same stack once it has been found
+	describe := func(ref stackOutput) (*client.StackInfo, error) {
+		mu.Lock()
+		ck := ref.region + "/" + ref.profile
+		if _, ok := clients[ck]; !ok {
+			cf, err := newClient(ref.region, ref.profile)
+			if err != nil {
+				mu.Unlock()
+				return nil, err
+			}
+			clients[ck] = client.New(cf)
+		}
+		c := clients[ck]
+
+		lk := ck + "/" + ref.stack
+		if _, ok := lookups[lk]; !ok {
+			lookups[lk] = &lookup{}
+		}
+		l := lookups[lk]
+		mu.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.si == nil {
+			si, err := c.Get(ref.stack)
+			if err != nil {
+				return nil, err
+			}
+			l.si = si
+		}
+		return l.si, nil
+	}
+
+	return func(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
+		ref, err := parseStackOutput(param, stack.Region(), stack.Profile())
+		if err != nil {
+			return nil, err
+		}
+
+		si, err := describe(ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to fetch stack `%s`", ref.stack)
+		}
+
+		if si == nil {
+			return nil, fmt.Errorf("unable to find stack `%s` in %s", ref.stack, ref.region)
+		}
+
+		for _, o := range si.Outputs {
+			if o.Key == ref.output {
+				return &stackParam{key: key, value: o.Value}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("unable to find output `%s` on stack `%s`", ref.output, ref.stack)
 	}
+}
+
+// NewExportResolver returns a resolver which looks up the value of a
+// cloudformation export, within the stack's region and account unless another
+// region is provided. Exports are listed once for each region and profile, and
+// listed again whenever an export isn't found.
+//
+// Export references add no dependencies between stacks, so an export must
+// already exist when a stack using it is deployed. Outputs of stacks within
+// the stacker directory should be referenced with the `Stack` resolver, which
+// orders deploys.
+//
+// Example Usage:
+//
+//   parameters:
+//     VpcId:
+//       Export: NetworkVpcId
+//     CertificateArn:
+//       Export:
+//         name: GlobalCertificateArn
+//         region: us-east-1
+func NewExportResolver(newClient func(region, profile string) (client.CloudformationClient, error)) Resolver {
+	var (
+		mu      sync.Mutex
+		exports = make(map[string]map[string]string)
+	)
+
+	// lookupExport returns the value of an export, only listing the exports
+	// of a region when they haven't been listed or don't include the name
+	lookupExport := func(name, region, profile string) (string, bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
 
-	for _, o := range si.Outputs {
-		if o.Key == outputName {
-			return &stackParam{key: key, value: o.Value}, nil
+		k := region + "/" + profile
+		if value, ok := exports[k][name]; ok {
+			return value, true, nil
 		}
+
+		cf, err := newClient(region, profile)
+		if err != nil {
+			return "", false, err
+		}
+
+		e, err := client.New(cf).ListExports()
+		if err != nil {
+			return "", false, err
+		}
+
+		exports[k] = e
+		value, ok := e[name]
+		return value, ok, nil
 	}
 
-	return nil, fmt.Errorf("unable to find output `%s` on stack `%s`", outputName, stackName)
+	return func(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
+		opts, ok := resolverOptions(param)
+		if !ok {
+			opts = map[string]string{"name": fmt.Sprint(param)}
+		}
+
+		for k := range opts {
+			if k != "name" && k != "region" {
+				return nil, fmt.Errorf("unknown option `%s`, expected `name` and optionally `region`", k)
+			}
+		}
+
+		name, region := opts["name"], opts["region"]
+		if name == "" {
+			return nil, errors.New("expected to receive an export `name`")
+		}
+		if region == "" {
+			region = stack.Region()
+		}
+
+		value, ok, err := lookupExport(name, region, stack.Profile())
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to fetch exports in %s", region)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unable to find export `%s` in %s", name, region)
+		}
+
+		return &stackParam{key: key, value: value}, nil
+	}
+}
+
+// stackOutput references an output of a stack, which may live in another
+// region or account
+type stackOutput struct {
+	stack   string
+	output  string
+	region  string
+	profile string
+}
+
+// parseStackOutput parses a stack output reference, given either in the format
+// <stack>.<output> or as a mapping. References default to the provided region
+// and profile.
+func parseStackOutput(param interface{}, region string, profile string) (stackOutput, error) {
+	ref := stackOutput{region: region, profile: profile}
+
+	opts, ok := resolverOptions(param)
+	if !ok {
+		s := strings.SplitN(fmt.Sprint(param), ".", 2)
+		if len(s) != 2 {
+			return ref, fmt.Errorf("expected to receive input in format <stack>.<output>")
+		}
+		ref.stack, ref.output = s[0], s[1]
+		return ref, nil
+	}
+
+	for k, v := range opts {
+		switch k {
+		case "stack":
+			ref.stack = v
+		case "output":
+			ref.output = v
+		case "region":
+			ref.region = v
+		case "profile":
+			ref.profile = v
+		default:
+			return ref, fmt.Errorf("unknown option `%s`, expected `stack`, `output` and optionally `region` or `profile`", k)
+		}
+	}
+
+	if ref.stack == "" || ref.output == "" {
+		return ref, errors.New("expected to receive a `stack` and an `output`")
+	}
+
+	return ref, nil
+}
+
+// stackDependencies walks a set of raw parameters and returns a reference to
+// every stack whose outputs are consumed through the `Stack` resolver,
+// including those substituted into `Sub` strings
+func stackDependencies(rp RawParams, region string, profile string) []stacker.StackRef {
+	refs := make([]stacker.StackRef, 0)
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		original := reflect.ValueOf(v)
+		switch original.Kind() {
+		case reflect.Map:
+			for _, k := range original.MapKeys() {
+				value := original.MapIndex(k).Interface()
+				switch fmt.Sprint(k) {
+				case "Stack":
+					if ref, err := parseStackOutput(value, region, profile); err == nil {
+						refs = append(refs, stacker.StackRef{Name: ref.stack, Region: ref.region, Profile: ref.profile})
+					}
+				case "Sub":
+					parts, err := parseSub(fmt.Sprint(value))
+					if err != nil {
+						continue
+					}
+					for _, p := range parts {
+						if p.resolver != "" {
+							walk(map[string]string{p.resolver: p.arg})
+						}
+					}
+				default:
+					walk(value)
+				}
+			}
+		case reflect.Slice:
+			for i := 0; i < original.Len(); i++ {
+				walk(original.Index(i).Interface())
+			}
+		}
+	}
+
+	for _, v := range rp {
+		walk(v)
+	}
+
+	return refs
 }
 
 func ResolveFile(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
@@ -58,3 +310,308 @@ func ResolveFile(key string, param interface{}, stack stacker.Stack) (stacker.St
 
 	return &stackParam{key: key, value: strings.TrimSuffix(string(b), "\n")}, nil
 }
+
+// ResolveEnv provides the value of an environment variable. The variable is
+// required unless a default is provided.
+//
+// Example Usage:
+//
+//   parameters:
+//     ImageTag:
+//       Env: IMAGE_TAG
+//     BuildNumber:
+//       Env:
+//         name: BUILD_NUMBER
+//         default: 0
+func ResolveEnv(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
+	opts, ok := resolverOptions(param)
+	if !ok {
+		opts = map[string]string{"name": fmt.Sprint(param)}
+	}
+
+	for k := range opts {
+		if k != "name" && k != "default" {
+			return nil, fmt.Errorf("unknown option `%s`, expected `name` and optionally `default`", k)
+		}
+	}
+
+	name := opts["name"]
+	if name == "" {
+		return nil, errors.New("expected to receive a variable `name`")
+	}
+
+	if value, ok := os.LookupEnv(name); ok {
+		return &stackParam{key: key, value: value}, nil
+	}
+
+	if value, ok := opts["default"]; ok {
+		return &stackParam{key: key, value: value}, nil
+	}
+
+	return nil, fmt.Errorf("environment variable `%s` required by parameter `%s` of stack `%s` is not set", name, key, stack.Name())
+}
+
+// ResolvePrevious keeps the value a parameter has on the deployed stack, such
+// as secrets which are managed outside of stacker. It can't be used when
+// creating a stack.
+//
+// Example Usage:
+//
+//   parameters:
+//     DatabasePassword:
+//       Previous: true
+func ResolvePrevious(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
+	if b, ok := param.(bool); !ok || !b {
+		return nil, fmt.Errorf("unexpected value `%v`, expected `Previous: true`", param)
+	}
+
+	return &stackParam{key: key, usePrevious: true}, nil
+}
+
+// NewCmdResolver returns a resolver which runs a shell command within the
+// stacker directory and provides its trimmed output. Commands run with a
+// minimal environment: PATH and HOME, along with the name and region of the
+// stack as STACKER_STACK_NAME and STACKER_STACK_REGION. A command fails when
+// it exits with a non-zero status, writes to stderr or runs for longer than
+// its timeout.
+//
+// Example Usage:
+//
+//   parameters:
+//     GitSHA:
+//       Cmd: git rev-parse HEAD
+//     ArtifactChecksum:
+//       Cmd:
+//         command: ./scripts/checksum.sh
+//         timeout: 60 # seconds
+func NewCmdResolver(dir string, timeout time.Duration) Resolver {
+	return func(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
+		opts, ok := resolverOptions(param)
+		if !ok {
+			opts = map[string]string{"command": fmt.Sprint(param)}
+		}
+
+		for k := range opts {
+			if k != "command" && k != "timeout" {
+				return nil, fmt.Errorf("unknown option `%s`, expected `command` and optionally `timeout`", k)
+			}
+		}
+
+		command := opts["command"]
+		if command == "" {
+			return nil, errors.New("expected to receive a `command`")
+		}
+
+		cmdTimeout := timeout
+		if t, ok := opts["timeout"]; ok {
+			seconds, err := strconv.Atoi(t)
+			if err != nil || seconds <= 0 {
+				return nil, fmt.Errorf("expected timeout to be a number of seconds, got `%s`", t)
+			}
+			cmdTimeout = time.Duration(seconds) * time.Second
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+		defer cancel()
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = dir
+		// Don't wait on processes started by the command which outlive it
+		cmd.WaitDelay = time.Second
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		cmd.Env = []string{
+			"PATH=" + os.Getenv("PATH"),
+			"HOME=" + os.Getenv("HOME"),
+			"STACKER_STACK_NAME=" + stack.Name(),
+			"STACKER_STACK_REGION=" + stack.Region(),
+		}
+
+		err := cmd.Run()
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("command `%s` timed out after %s", command, cmdTimeout)
+		}
+		if err != nil && stderr.Len() > 0 {
+			return nil, errors.Wrapf(err, "command `%s` failed with `%s`", command, strings.TrimSpace(stderr.String()))
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "command `%s` failed", command)
+		}
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("command `%s` wrote to stderr: %s", command, strings.TrimSpace(stderr.String()))
+		}
+
+		return &stackParam{key: key, value: strings.TrimSpace(stdout.String())}, nil
+	}
+}
+
+// NewSSMResolver returns a resolver which looks up a parameter from the
+// Systems Manager parameter store within the stack's region. SecureString
+// parameters are decrypted, and marked as sensitive.
+//
+// Example Usage:
+//
+//   parameters:
+//     DatabaseHost:
+//       SSM: /production/database/host
+//     DatabasePassword:
+//       SSM:
+//         name: /production/database/password
+//         version: 3
+func NewSSMResolver(newClient func(region, profile string) (client.SSMClient, error)) Resolver {
+	return func(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
+		name, err := parseSSMParameter(param)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := newClient(stack.Region(), stack.Profile())
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create ssm client")
+		}
+
+		output, err := c.GetParameter(&ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to fetch parameter `%s`", name)
+		}
+
+		return &stackParam{
+			key:       key,
+			value:     aws.StringValue(output.Parameter.Value),
+			sensitive: aws.StringValue(output.Parameter.Type) == ssm.ParameterTypeSecureString,
+		}, nil
+	}
+}
+
+// parseSSMParameter returns the name of a parameter, with a version selector
+// appended when a specific version is requested
+func parseSSMParameter(param interface{}) (string, error) {
+	opts, ok := resolverOptions(param)
+	if !ok {
+		return fmt.Sprint(param), nil
+	}
+
+	for k := range opts {
+		if k != "name" && k != "version" {
+			return "", fmt.Errorf("unknown option `%s`, expected `name` and optionally `version`", k)
+		}
+	}
+
+	name := opts["name"]
+	if name == "" {
+		return "", errors.New("expected to receive a parameter `name`")
+	}
+
+	if version := opts["version"]; version != "" {
+		return fmt.Sprintf("%s:%s", name, version), nil
+	}
+	return name, nil
+}
+
+// NewSecretResolver returns a resolver which looks up a secret from Secrets
+// Manager within the stack's region. A single key may be extracted from
+// secrets which hold a JSON object. Secret values are marked as sensitive.
+//
+// Example Usage:
+//
+//   parameters:
+//     ApiToken:
+//       Secret: production/api-token
+//     DatabasePassword:
+//       Secret:
+//         secret-id: production/database
+//         json_key: password
+//         version_stage: AWSPREVIOUS
+func NewSecretResolver(newClient func(region, profile string) (client.SecretsManagerClient, error)) Resolver {
+	return func(key string, param interface{}, stack stacker.Stack) (stacker.StackParam, error) {
+		opts, ok := resolverOptions(param)
+		if !ok {
+			opts = map[string]string{"secret-id": fmt.Sprint(param)}
+		}
+
+		for k := range opts {
+			if k == "secret_id" {
+				return nil, errors.New("unknown option `secret_id`, the secret is given as `secret-id`")
+			}
+			if k != "secret-id" && k != "json_key" && k != "version_stage" {
+				return nil, fmt.Errorf("unknown option `%s`, expected `secret-id` and optionally `json_key` or `version_stage`", k)
+			}
+		}
+
+		secretID := opts["secret-id"]
+		if secretID == "" {
+			return nil, errors.New("expected to receive a `secret-id`")
+		}
+
+		input := &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID)}
+		if stage := opts["version_stage"]; stage != "" {
+			input.VersionStage = aws.String(stage)
+		}
+
+		c, err := newClient(stack.Region(), stack.Profile())
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create secrets manager client")
+		}
+
+		output, err := c.GetSecretValue(input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to fetch secret `%s`", secretID)
+		}
+
+		if output.SecretString == nil {
+			return nil, fmt.Errorf("secret `%s` does not contain a string value", secretID)
+		}
+
+		value := *output.SecretString
+		if jsonKey := opts["json_key"]; jsonKey != "" {
+			if value, err = secretJSONValue(value, jsonKey); err != nil {
+				return nil, errors.Wrapf(err, "unable to read secret `%s`", secretID)
+			}
+		}
+
+		return &stackParam{key: key, value: value, sensitive: true}, nil
+	}
+}
+
+// secretJSONValue extracts the value of a key from a secret holding a JSON object
+func secretJSONValue(secret string, key string) (string, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &values); err != nil {
+		return "", errors.New("secret is not a JSON object")
+	}
+
+	v, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("secret does not contain key `%s`", key)
+	}
+
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+
+	// Non-string values are passed as their JSON representation
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// resolverOptions returns the options of a resolver which accepts a mapping of
+// options, and false when the resolver was given a single value
+func resolverOptions(param interface{}) (map[string]string, bool) {
+	original := reflect.ValueOf(param)
+	if original.Kind() != reflect.Map {
+		return nil, false
+	}
+
+	opts := make(map[string]string)
+	for _, k := range original.MapKeys() {
+		opts[fmt.Sprint(k.Interface())] = fmt.Sprint(original.MapIndex(k).Interface())
+	}
+	return opts, true
+}
@@ -1,10 +1,20 @@
 package backend
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
+	"github.com/eyeamera/stacker-cli/client"
 	"github.com/eyeamera/stacker-cli/stacker"
 )
 
@@ -41,3 +51,639 @@ func TestResolveFile(t *testing.T) {
 	}
 
 }
+
+func TestResolveEnv(t *testing.T) {
+	os.Setenv("STACKER_TEST_IMAGE_TAG", "v1.2.3")
+	os.Setenv("STACKER_TEST_EMPTY", "")
+	os.Unsetenv("STACKER_TEST_UNSET")
+	defer os.Unsetenv("STACKER_TEST_IMAGE_TAG")
+	defer os.Unsetenv("STACKER_TEST_EMPTY")
+
+	cases := []struct {
+		key   string
+		param interface{}
+		stack stacker.Stack
+
+		expected stacker.StackParam
+		errored  bool
+	}{
+		{
+			"foo", "STACKER_TEST_IMAGE_TAG", &stack{name: "API"},
+			&stackParam{key: "foo", value: "v1.2.3"}, false,
+		},
+		{
+			"foo", "STACKER_TEST_EMPTY", &stack{name: "API"},
+			&stackParam{key: "foo", value: ""}, false,
+		},
+		{
+			"foo", map[interface{}]interface{}{"name": "STACKER_TEST_IMAGE_TAG", "default": "latest"}, &stack{name: "API"},
+			&stackParam{key: "foo", value: "v1.2.3"}, false,
+		},
+		{
+			"foo", map[interface{}]interface{}{"name": "STACKER_TEST_UNSET", "default": 0}, &stack{name: "API"},
+			&stackParam{key: "foo", value: "0"}, false,
+		},
+		{
+			"foo", "STACKER_TEST_UNSET", &stack{name: "API"},
+			nil, true,
+		},
+		{
+			"foo", map[interface{}]interface{}{"name": "STACKER_TEST_UNSET"}, &stack{name: "API"},
+			nil, true,
+		},
+		{
+			"foo", map[interface{}]interface{}{"default": "latest"}, &stack{name: "API"},
+			nil, true,
+		},
+		{
+			"foo", map[interface{}]interface{}{"name": "STACKER_TEST_IMAGE_TAG", "required": true}, &stack{name: "API"},
+			nil, true,
+		},
+	}
+
+	for _, c := range cases {
+		r, err := ResolveEnv(c.key, c.param, c.stack)
+
+		assert.Equal(t, c.expected, r)
+
+		if c.errored {
+			assert.Error(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+
+	_, err := ResolveEnv("ImageTag", "STACKER_TEST_UNSET", &stack{name: "API"})
+	assert.EqualError(t, err, "environment variable `STACKER_TEST_UNSET` required by parameter `ImageTag` of stack `API` is not set")
+}
+
+func TestResolvePrevious(t *testing.T) {
+	cases := []struct {
+		param interface{}
+
+		expected stacker.StackParam
+		errored  bool
+	}{
+		{true, &stackParam{key: "foo", usePrevious: true}, false},
+		{false, nil, true},
+		{"true", nil, true},
+	}
+
+	for _, c := range cases {
+		r, err := ResolvePrevious("foo", c.param, &stack{})
+
+		assert.Equal(t, c.expected, r)
+
+		if c.errored {
+			assert.Error(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestCmdResolver(t *testing.T) {
+	os.Setenv("STACKER_TEST_SECRET", "leaked")
+	defer os.Unsetenv("STACKER_TEST_SECRET")
+
+	resolve := NewCmdResolver("../test", time.Second)
+
+	cases := []struct {
+		param interface{}
+
+		expected stacker.StackParam
+		err      string
+	}{
+		{
+			"echo '  abc123  '",
+			&stackParam{key: "foo", value: "abc123"}, "",
+		},
+		{
+			"cat data.txt",
+			&stackParam{key: "foo", value: "101010"}, "",
+		},
+		{
+			"echo $STACKER_STACK_NAME $STACKER_STACK_REGION $STACKER_TEST_SECRET",
+			&stackParam{key: "foo", value: "API us-west-2"}, "",
+		},
+		{
+			map[interface{}]interface{}{"command": "sleep 1.2 && echo done", "timeout": 2},
+			&stackParam{key: "foo", value: "done"}, "",
+		},
+		{
+			"exit 3",
+			nil, "command `exit 3` failed: exit status 3",
+		},
+		{
+			"echo oops >&2; exit 1",
+			nil, "command `echo oops >&2; exit 1` failed with `oops`: exit status 1",
+		},
+		{
+			"echo value; echo warning >&2",
+			nil, "command `echo value; echo warning >&2` wrote to stderr: warning",
+		},
+		{
+			"sleep 5",
+			nil, "command `sleep 5` timed out after 1s",
+		},
+		{
+			map[interface{}]interface{}{"command": "echo hi", "timeout": "soon"},
+			nil, "expected timeout to be a number of seconds, got `soon`",
+		},
+		{
+			map[interface{}]interface{}{"timeout": 5},
+			nil, "expected to receive a `command`",
+		},
+	}
+
+	for _, c := range cases {
+		r, err := resolve("foo", c.param, &stack{name: "API", region: "us-west-2"})
+
+		assert.Equal(t, c.expected, r)
+
+		if c.err != "" {
+			assert.EqualError(t, err, c.err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+type mockCloudformation struct {
+	client.CloudformationClient
+	mock.Mock
+}
+
+func (c *mockCloudformation) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	r := c.Called(input)
+	so, _ := r.Get(0).(*cloudformation.DescribeStacksOutput)
+	return so, r.Error(1)
+}
+
+func TestStackOutputResolver(t *testing.T) {
+	describe := func(name string, outputs map[string]string) *cloudformation.DescribeStacksOutput {
+		s := &cloudformation.Stack{
+			StackName:    aws.String(name),
+			StackStatus:  aws.String("CREATE_COMPLETE"),
+			CreationTime: aws.Time(time.Now()),
+		}
+		for k, v := range outputs {
+			s.Outputs = append(s.Outputs, &cloudformation.Output{OutputKey: aws.String(k), OutputValue: aws.String(v)})
+		}
+		return &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{s}}
+	}
+
+	var (
+		local  = &mockCloudformation{}
+		global = &mockCloudformation{}
+		shared = &mockCloudformation{}
+
+		clients = map[string]*mockCloudformation{
+			"us-west-2/":       local,
+			"us-east-1/":       global,
+			"us-east-1/shared": shared,
+		}
+		created = make(map[string]int)
+	)
+
+	local.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String("VPC")}).
+		Return(describe("VPC", map[string]string{"VpcId": "vpc-123"}), nil)
+	global.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String("Certificates")}).
+		Return(describe("Certificates", map[string]string{"Arn": "arn:global"}), nil)
+	shared.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String("Certificates")}).
+		Return(describe("Certificates", map[string]string{"Arn": "arn:shared"}), nil)
+	local.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String("Missing")}).
+		Return(&cloudformation.DescribeStacksOutput{}, nil)
+
+	resolve := NewStackOutputResolver(func(region, profile string) (client.CloudformationClient, error) {
+		created[region+"/"+profile]++
+		return clients[region+"/"+profile], nil
+	})
+
+	cases := []struct {
+		param interface{}
+
+		expected stacker.StackParam
+		errored  bool
+	}{
+		{"VPC.VpcId", &stackParam{key: "foo", value: "vpc-123"}, false},
+		{
+			map[interface{}]interface{}{"stack": "VPC", "output": "VpcId"},
+			&stackParam{key: "foo", value: "vpc-123"}, false,
+		},
+		{
+			map[interface{}]interface{}{"stack": "Certificates", "output": "Arn", "region": "us-east-1"},
+			&stackParam{key: "foo", value: "arn:global"}, false,
+		},
+		{
+			map[interface{}]interface{}{"stack": "Certificates", "output": "Arn", "region": "us-east-1", "profile": "shared"},
+			&stackParam{key: "foo", value: "arn:shared"}, false,
+		},
+		{"VPC.SubnetId", nil, true},
+		{"Missing.VpcId", nil, true},
+		{"VPC", nil, true},
+		{map[interface{}]interface{}{"stack": "VPC"}, nil, true},
+		{map[interface{}]interface{}{"stack": "VPC", "output": "VpcId", "account": "123"}, nil, true},
+	}
+
+	for _, c := range cases {
+		r, err := resolve("foo", c.param, &stack{region: "us-west-2"})
+
+		assert.Equal(t, c.expected, r)
+
+		if c.errored {
+			assert.Error(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+
+	// References default to the profile of the stack
+	r, err := resolve("foo", "Certificates.Arn", &stack{region: "us-east-1", profile: "shared"})
+	assert.Nil(t, err)
+	assert.Equal(t, &stackParam{key: "foo", value: "arn:shared"}, r)
+
+	assert.Equal(t, map[string]int{"us-west-2/": 1, "us-east-1/": 1, "us-east-1/shared": 1}, created)
+}
+
+func TestStackOutputResolverCache(t *testing.T) {
+	cf := &mockCloudformation{}
+	cf.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String("VPC")}).
+		Return(&cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{{
+			StackName:    aws.String("VPC"),
+			StackStatus:  aws.String("CREATE_COMPLETE"),
+			CreationTime: aws.Time(time.Now()),
+			Outputs:      []*cloudformation.Output{{OutputKey: aws.String("VpcId"), OutputValue: aws.String("vpc-123")}},
+		}}}, nil).Once()
+
+	pr := NewParamsResolver()
+	pr.Add("Stack", NewStackOutputResolver(func(region, profile string) (client.CloudformationClient, error) {
+		return cf, nil
+	}))
+
+	rp := make(RawParams)
+	for i := 0; i < 10; i++ {
+		rp[fmt.Sprintf("Subnet%d", i)] = map[interface{}]interface{}{"Stack": "VPC.VpcId"}
+	}
+
+	sps, err := pr.Resolve(rp, &stack{region: "us-west-2"})
+
+	assert.Nil(t, err)
+	assert.Len(t, sps, 10)
+	for _, sp := range sps {
+		assert.Equal(t, "vpc-123", sp.Value())
+	}
+	cf.AssertNumberOfCalls(t, "DescribeStacks", 1)
+
+	// Failed lookups and missing stacks aren't cached
+	cf.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String("Cache")}).
+		Return(nil, errors.New("throttled")).Once()
+	cf.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String("Cache")}).
+		Return(&cloudformation.DescribeStacksOutput{}, nil).Once()
+	cf.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String("Cache")}).
+		Return(&cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{{
+			StackName:    aws.String("Cache"),
+			StackStatus:  aws.String("CREATE_COMPLETE"),
+			CreationTime: aws.Time(time.Now()),
+			Outputs:      []*cloudformation.Output{{OutputKey: aws.String("Endpoint"), OutputValue: aws.String("cache.local")}},
+		}}}, nil).Once()
+
+	rp = RawParams{"Endpoint": map[interface{}]interface{}{"Stack": "Cache.Endpoint"}}
+
+	_, err = pr.Resolve(rp, &stack{region: "us-west-2"})
+	assert.Error(t, err)
+	_, err = pr.Resolve(rp, &stack{region: "us-west-2"})
+	assert.Error(t, err)
+
+	for i := 0; i < 2; i++ {
+		sps, err = pr.Resolve(rp, &stack{region: "us-west-2"})
+		assert.Nil(t, err)
+		assert.Equal(t, "cache.local", sps[0].Value())
+	}
+	cf.AssertExpectations(t)
+}
+
+func (c *mockCloudformation) ListExportsPages(input *cloudformation.ListExportsInput, fn func(*cloudformation.ListExportsOutput, bool) bool) error {
+	r := c.Called(input)
+	pages, _ := r.Get(0).([]*cloudformation.ListExportsOutput)
+	for i, p := range pages {
+		if !fn(p, i == len(pages)-1) {
+			break
+		}
+	}
+	return r.Error(1)
+}
+
+func TestExportResolver(t *testing.T) {
+	var (
+		local   = &mockCloudformation{}
+		global  = &mockCloudformation{}
+		broken  = &mockCloudformation{}
+		clients = map[string]*mockCloudformation{
+			"us-west-2":    local,
+			"us-east-1":    global,
+			"eu-central-1": broken,
+		}
+	)
+
+	local.On("ListExportsPages", &cloudformation.ListExportsInput{}).Once().Return([]*cloudformation.ListExportsOutput{
+		{
+			Exports:   []*cloudformation.Export{{Name: aws.String("NetworkVpcId"), Value: aws.String("vpc-123")}},
+			NextToken: aws.String("page-2"),
+		},
+		{
+			Exports: []*cloudformation.Export{{Name: aws.String("NetworkSubnets"), Value: aws.String("subnet-1,subnet-2")}},
+		},
+	}, nil)
+	// Exported by a stack deployed after the exports were first listed
+	local.On("ListExportsPages", &cloudformation.ListExportsInput{}).Return([]*cloudformation.ListExportsOutput{
+		{
+			Exports: []*cloudformation.Export{
+				{Name: aws.String("NetworkVpcId"), Value: aws.String("vpc-123")},
+				{Name: aws.String("NetworkSubnets"), Value: aws.String("subnet-1,subnet-2")},
+				{Name: aws.String("NetworkZoneId"), Value: aws.String("zone-123")},
+			},
+		},
+	}, nil)
+	global.On("ListExportsPages", &cloudformation.ListExportsInput{}).Once().Return([]*cloudformation.ListExportsOutput{
+		{Exports: []*cloudformation.Export{{Name: aws.String("GlobalCertificateArn"), Value: aws.String("arn:cert")}}},
+	}, nil)
+	broken.On("ListExportsPages", &cloudformation.ListExportsInput{}).Return(nil, errors.New("boom"))
+
+	resolve := NewExportResolver(func(region, profile string) (client.CloudformationClient, error) {
+		return clients[region], nil
+	})
+
+	cases := []struct {
+		param interface{}
+
+		expected stacker.StackParam
+		errored  bool
+	}{
+		{"NetworkVpcId", &stackParam{key: "foo", value: "vpc-123"}, false},
+		{"NetworkSubnets", &stackParam{key: "foo", value: "subnet-1,subnet-2"}, false},
+		{
+			map[interface{}]interface{}{"name": "GlobalCertificateArn", "region": "us-east-1"},
+			&stackParam{key: "foo", value: "arn:cert"}, false,
+		},
+		{"NetworkZoneId", &stackParam{key: "foo", value: "zone-123"}, false},
+		{"NetworkZoneId", &stackParam{key: "foo", value: "zone-123"}, false},
+		{"GlobalCertificateArn", nil, true},
+		{map[interface{}]interface{}{"name": "NetworkVpcId", "region": "eu-central-1"}, nil, true},
+		{map[interface{}]interface{}{"region": "us-east-1"}, nil, true},
+		{map[interface{}]interface{}{"name": "NetworkVpcId", "stack": "VPC"}, nil, true},
+	}
+
+	for _, c := range cases {
+		r, err := resolve("foo", c.param, &stack{region: "us-west-2"})
+
+		assert.Equal(t, c.expected, r)
+
+		if c.errored {
+			assert.Error(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+
+	// Exports are listed once per region, and again when an export isn't found
+	local.AssertNumberOfCalls(t, "ListExportsPages", 3)
+	global.AssertExpectations(t)
+}
+
+func TestStackDependencies(t *testing.T) {
+	rp := RawParams{
+		"Name":  "literal",
+		"VpcId": map[interface{}]interface{}{"Stack": "VPC.VpcId"},
+		"Subnets": []interface{}{
+			map[interface{}]interface{}{"Stack": "SubnetA.Subnet"},
+			map[string]string{"Stack": "SubnetB.Subnet"},
+		},
+		"Certificate": map[interface{}]interface{}{
+			"Stack": map[interface{}]interface{}{"stack": "Certificates", "output": "Arn", "region": "us-west-2"},
+		},
+		"BucketArn": map[interface{}]interface{}{
+			"Sub": "arn:aws:s3:::${Stack:Storage.BucketName}/${Env:STAGE}/*",
+		},
+		"Global": map[interface{}]interface{}{
+			"Stack": map[interface{}]interface{}{"stack": "Zones", "output": "Id", "profile": "global"},
+		},
+		"Invalid": map[string]string{"Stack": "NoOutput"},
+		"Data":    map[string]string{"File": "../test/data.txt"},
+	}
+
+	expected := []stacker.StackRef{
+		{Name: "VPC", Region: "us-east-1", Profile: "shared"},
+		{Name: "SubnetA", Region: "us-east-1", Profile: "shared"},
+		{Name: "SubnetB", Region: "us-east-1", Profile: "shared"},
+		{Name: "Certificates", Region: "us-west-2", Profile: "shared"},
+		{Name: "Storage", Region: "us-east-1", Profile: "shared"},
+		{Name: "Zones", Region: "us-east-1", Profile: "global"},
+	}
+
+	assert.ElementsMatch(t, expected, stackDependencies(rp, "us-east-1", "shared"))
+}
+
+type mockSSM struct {
+	mock.Mock
+}
+
+func (c *mockSSM) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	r := c.Called(input)
+	so, _ := r.Get(0).(*ssm.GetParameterOutput)
+	return so, r.Error(1)
+}
+
+func TestSSMResolver(t *testing.T) {
+	cases := []struct {
+		param interface{}
+
+		name     string
+		response *ssm.GetParameterOutput
+		err      error
+
+		expected stacker.StackParam
+		errored  bool
+	}{
+		{
+			"/production/database/host",
+			"/production/database/host", &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("db.internal")}}, nil,
+			&stackParam{key: "foo", value: "db.internal"}, false,
+		},
+		{
+			map[interface{}]interface{}{"name": "/production/database/password", "version": 3},
+			"/production/database/password:3", &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("hunter2")}}, nil,
+			&stackParam{key: "foo", value: "hunter2"}, false,
+		},
+		{
+			map[interface{}]interface{}{"name": "/production/database/password"},
+			"/production/database/password", &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("hunter3")}}, nil,
+			&stackParam{key: "foo", value: "hunter3"}, false,
+		},
+		{
+			"/production/database/secret",
+			"/production/database/secret", &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("hunter4"), Type: aws.String("SecureString")}}, nil,
+			&stackParam{key: "foo", value: "hunter4", sensitive: true}, false,
+		},
+		{
+			"/production/missing",
+			"/production/missing", nil, errors.New("ParameterNotFound"),
+			nil, true,
+		},
+		{
+			map[interface{}]interface{}{"version": 3},
+			"", nil, nil,
+			nil, true,
+		},
+		{
+			map[interface{}]interface{}{"name": "/production/database/host", "region": "us-east-1"},
+			"", nil, nil,
+			nil, true,
+		},
+	}
+
+	for _, c := range cases {
+		m := &mockSSM{}
+		if c.name != "" {
+			m.On("GetParameter", &ssm.GetParameterInput{
+				Name:           aws.String(c.name),
+				WithDecryption: aws.Bool(true),
+			}).Once().Return(c.response, c.err)
+		}
+
+		var region string
+		resolve := NewSSMResolver(func(r, profile string) (client.SSMClient, error) {
+			region = r
+			return m, nil
+		})
+
+		r, err := resolve("foo", c.param, &stack{region: "us-west-2"})
+
+		assert.Equal(t, c.expected, r)
+		m.AssertExpectations(t)
+
+		if c.errored {
+			assert.Error(t, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, "us-west-2", region)
+		}
+	}
+
+	resolve := NewSSMResolver(func(string, string) (client.SSMClient, error) {
+		return nil, errors.New("no credentials")
+	})
+	_, err := resolve("foo", "/production/database/host", &stack{region: "us-west-2"})
+	assert.EqualError(t, err, "unable to create ssm client: no credentials")
+}
+
+type mockSecretsManager struct {
+	mock.Mock
+}
+
+func (c *mockSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	r := c.Called(input)
+	so, _ := r.Get(0).(*secretsmanager.GetSecretValueOutput)
+	return so, r.Error(1)
+}
+
+func TestSecretResolver(t *testing.T) {
+	credentials := `{"username": "admin", "password": "hunter2", "port": 5432}`
+
+	cases := []struct {
+		param interface{}
+
+		input    *secretsmanager.GetSecretValueInput
+		response *secretsmanager.GetSecretValueOutput
+		err      error
+
+		expected stacker.StackParam
+		errored  bool
+	}{
+		{
+			"production/api-token",
+			&secretsmanager.GetSecretValueInput{SecretId: aws.String("production/api-token")},
+			&secretsmanager.GetSecretValueOutput{SecretString: aws.String("abc123")}, nil,
+			&stackParam{key: "foo", value: "abc123", sensitive: true}, false,
+		},
+		{
+			map[interface{}]interface{}{"secret-id": "production/database", "json_key": "password"},
+			&secretsmanager.GetSecretValueInput{SecretId: aws.String("production/database")},
+			&secretsmanager.GetSecretValueOutput{SecretString: aws.String(credentials)}, nil,
+			&stackParam{key: "foo", value: "hunter2", sensitive: true}, false,
+		},
+		{
+			map[interface{}]interface{}{"secret-id": "production/database", "json_key": "port", "version_stage": "AWSPREVIOUS"},
+			&secretsmanager.GetSecretValueInput{SecretId: aws.String("production/database"), VersionStage: aws.String("AWSPREVIOUS")},
+			&secretsmanager.GetSecretValueOutput{SecretString: aws.String(credentials)}, nil,
+			&stackParam{key: "foo", value: "5432", sensitive: true}, false,
+		},
+		{
+			map[interface{}]interface{}{"secret-id": "production/database", "json_key": "hostname"},
+			&secretsmanager.GetSecretValueInput{SecretId: aws.String("production/database")},
+			&secretsmanager.GetSecretValueOutput{SecretString: aws.String(credentials)}, nil,
+			nil, true,
+		},
+		{
+			map[interface{}]interface{}{"secret-id": "production/api-token", "json_key": "token"},
+			&secretsmanager.GetSecretValueInput{SecretId: aws.String("production/api-token")},
+			&secretsmanager.GetSecretValueOutput{SecretString: aws.String("abc123")}, nil,
+			nil, true,
+		},
+		{
+			"production/certificate",
+			&secretsmanager.GetSecretValueInput{SecretId: aws.String("production/certificate")},
+			&secretsmanager.GetSecretValueOutput{SecretBinary: []byte{0x01}}, nil,
+			nil, true,
+		},
+		{
+			"production/missing",
+			&secretsmanager.GetSecretValueInput{SecretId: aws.String("production/missing")},
+			nil, errors.New("ResourceNotFoundException"),
+			nil, true,
+		},
+		{
+			map[interface{}]interface{}{"json_key": "password"},
+			nil, nil, nil,
+			nil, true,
+		},
+		{
+			map[interface{}]interface{}{"secret-id": "production/database", "key": "password"},
+			nil, nil, nil,
+			nil, true,
+		},
+		{
+			map[interface{}]interface{}{"secret_id": "production/database"},
+			nil, nil, nil,
+			nil, true,
+		},
+	}
+
+	for _, c := range cases {
+		m := &mockSecretsManager{}
+		if c.input != nil {
+			m.On("GetSecretValue", c.input).Once().Return(c.response, c.err)
+		}
+
+		resolve := NewSecretResolver(func(region, profile string) (client.SecretsManagerClient, error) {
+			return m, nil
+		})
+
+		r, err := resolve("foo", c.param, &stack{region: "us-west-2"})
+
+		assert.Equal(t, c.expected, r)
+		m.AssertExpectations(t)
+
+		if c.errored {
+			assert.Error(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+
+	resolve := NewSecretResolver(func(string, string) (client.SecretsManagerClient, error) {
+		return nil, errors.New("no credentials")
+	})
+	_, err := resolve("foo", "production/api-token", &stack{region: "us-west-2"})
+	assert.EqualError(t, err, "unable to create secrets manager client: no credentials")
+}
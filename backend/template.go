@@ -1,11 +1,15 @@
 package backend
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	texttemplate "text/template"
 
 	"github.com/awslabs/goformation"
 	"github.com/awslabs/goformation/cloudformation"
@@ -15,69 +19,138 @@ var (
 	templateExtensions = []string{".yaml", ".yml", ".json"}
 )
 
+// templateSuffix marks a template which is rendered with text/template, using
+// the stack's template_vars, before it is parsed, e.g. `Subnets.yml.tmpl`
+const templateSuffix = ".tmpl"
+
 type Template interface {
+	Path() string
 	Body() string
 	Parameters() []string
+	Parameter(name string) *TemplateParameter
 }
 
 type template struct {
-	body       string
-	parameters []string // List of parameter names
+	path        string
+	body        string
+	parameters  []string // List of parameter names
+	definitions map[string]*TemplateParameter
 }
 
+func (t *template) Path() string         { return t.path }
 func (t *template) Body() string         { return t.body }
 func (t *template) Parameters() []string { return t.parameters }
 
+// Parameter returns the declaration of a template parameter, or nil when the
+// template doesn't declare it
+func (t *template) Parameter(name string) *TemplateParameter {
+	return t.definitions[name]
+}
+
 type TemplateStore interface {
-	Fetch(name string) (Template, error)
+	Fetch(name string, vars map[string]interface{}) (Template, error)
 }
 
 type templateStore struct {
 	path string
+	dir  string // Stacker directory, which include paths are relative to
 	d    map[string]*template
 }
 
 func newTemplateStore(path string) *templateStore {
 	return &templateStore{
 		path: path,
+		dir:  filepath.Dir(path),
 		d:    make(map[string]*template),
 	}
 }
 
-func (ts *templateStore) Fetch(name string) (Template, error) {
+// Fetch returns a template by name. Templated files are rendered with vars,
+// which are ignored for any other template.
+func (ts *templateStore) Fetch(name string, vars map[string]interface{}) (Template, error) {
 	if t, ok := ts.d[name]; ok {
 		return t, nil
 	}
 
-	// check known extensions for template file
-	for _, ext := range templateExtensions {
-		p := path.Join(ts.path, name+ext)
+	p, ok := ts.find(name)
+	if !ok {
+		return nil, fmt.Errorf("unable to locate template %s", name)
+	}
 
-		if _, err := os.Stat(p); err != nil {
-			continue
-		}
+	// Rendered templates differ between stacks, so they aren't cached
+	if strings.HasSuffix(p, templateSuffix) {
+		return renderTemplate(p, ts.dir, vars)
+	}
 
-		t, err := parseTemplate(p)
-		if err != nil {
-			return nil, err
+	t, err := parseTemplate(p, ts.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ts.d[name] = t
+
+	return t, nil
+}
+
+// find returns the path of a template, checking each known extension with and
+// without the templated suffix
+func (ts *templateStore) find(name string) (string, bool) {
+	for _, ext := range templateExtensions {
+		for _, suffix := range []string{"", templateSuffix} {
+			p := path.Join(ts.path, name+ext+suffix)
+
+			if _, err := os.Stat(p); err == nil {
+				return p, true
+			}
 		}
+	}
 
-		ts.d[name] = t
+	return "", false
+}
 
-		return t, nil
+// parseTemplate parses a template file, expanding includes relative to dir
+func parseTemplate(path string, dir string) (*template, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("unable to locate template %s", name)
+	return parseTemplateBody(path, dir, raw)
 }
 
-func parseTemplate(path string) (*template, error) {
+// renderTemplate executes a templated file with vars, then parses the result.
+// Referencing a var which isn't set is an error.
+func renderTemplate(path string, dir string, vars map[string]interface{}) (*template, error) {
 	raw, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
+	tmpl, err := texttemplate.New(filepath.Base(path)).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %s", path, err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, jsonValue(vars)); err != nil {
+		return nil, fmt.Errorf("unable to render template %s: %s", path, err)
+	}
+
+	return parseTemplateBody(path, dir, body.Bytes())
+}
+
+// parseTemplateBody parses a template's body once its includes have been
+// expanded, using the extension of its path to determine whether it's YAML or
+// JSON
+func parseTemplateBody(path string, dir string, raw []byte) (*template, error) {
+	// Include errors already name the file and line they occurred at
+	raw, err := expandIncludes(path, dir, raw)
+	if err != nil {
+		return nil, err
+	}
+
 	var cft *cloudformation.Template
-	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+	if isYAML(path) {
 		cft, err = goformation.ParseYAML(raw)
 	} else {
 		cft, err = goformation.ParseJSON(raw)
@@ -87,12 +160,28 @@ func parseTemplate(path string) (*template, error) {
 	}
 
 	p := make([]string, 0)
-	for k, _ := range cft.Parameters {
+	d := make(map[string]*TemplateParameter)
+	for k, v := range cft.Parameters {
+		tp, err := parseTemplateParameter(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %s: parameter %s: %s", path, k, err)
+		}
+
 		p = append(p, k)
+		d[k] = tp
 	}
+	sort.Strings(p)
 
 	return &template{
-		body:       string(raw),
-		parameters: p,
+		path:        path,
+		body:        string(raw),
+		parameters:  p,
+		definitions: d,
 	}, nil
 }
+
+// isYAML returns whether a template is YAML, rather than JSON
+func isYAML(path string) bool {
+	format := strings.TrimSuffix(path, templateSuffix)
+	return strings.HasSuffix(format, ".yaml") || strings.HasSuffix(format, ".yml")
+}
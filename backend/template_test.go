@@ -13,7 +13,7 @@ const TestTemplatesDir = "../test/stacker/templates"
 func TestTemplateStoreFetch(t *testing.T) {
 	ts := newTemplateStore(TestTemplatesDir)
 
-	template, _ := ts.Fetch("VPCYaml")
+	template, _ := ts.Fetch("VPCYaml", nil)
 
 	data, _ := ioutil.ReadFile(path.Join(TestTemplatesDir, "VPCYaml.yml"))
 	body := string(data)
@@ -21,3 +21,125 @@ func TestTemplateStoreFetch(t *testing.T) {
 	assert.EqualValues(t, []string{"Name", "VpcCIDR"}, template.Parameters())
 	assert.Equal(t, body, template.Body())
 }
+
+func TestTemplateStoreFetchParameters(t *testing.T) {
+	ts := newTemplateStore(TestTemplatesDir)
+
+	template, err := ts.Fetch("Database", nil)
+
+	min, max := 20.0, 1024.0
+	minLength, maxLength, passwordLength := 3.0, 16.0, 8.0
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Engine", "Name", "Password", "Storage", "SubnetIds"}, template.Parameters())
+	assert.Equal(t, &TemplateParameter{
+		Type:           "String",
+		AllowedPattern: "[a-z][a-z0-9]*",
+		MinLength:      &minLength,
+		MaxLength:      &maxLength,
+	}, template.Parameter("Name"))
+	assert.Equal(t, &TemplateParameter{
+		Type:          "String",
+		HasDefault:    true,
+		AllowedValues: []string{"mysql", "postgres"},
+	}, template.Parameter("Engine"))
+	assert.Equal(t, &TemplateParameter{Type: "Number", MinValue: &min, MaxValue: &max}, template.Parameter("Storage"))
+	assert.Equal(t, &TemplateParameter{Type: "String", NoEcho: true, MinLength: &passwordLength}, template.Parameter("Password"))
+	assert.Nil(t, template.Parameter("Missing"))
+}
+
+func TestTemplateStoreFetchRendered(t *testing.T) {
+	ts := newTemplateStore(TestTemplatesDir)
+
+	template, err := ts.Fetch("Subnets", map[string]interface{}{
+		"Environment": "staging",
+		"Subnets": []interface{}{
+			map[interface{}]interface{}{"Zone": "us-east-1a", "CIDR": "10.0.0.0/24"},
+			map[interface{}]interface{}{"Zone": "us-east-1b", "CIDR": "10.0.1.0/24"},
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, TestTemplatesDir+"/Subnets.yml.tmpl", template.Path())
+	assert.Equal(t, []string{"VpcId"}, template.Parameters())
+	assert.Equal(t, `AWSTemplateFormatVersion: '2010-09-09'
+Description: Creates a subnet within each availability zone.
+Parameters:
+  VpcId:
+    Type: AWS::EC2::VPC::Id
+Resources:
+  Subnet0:
+    Type: AWS::EC2::Subnet
+    Properties:
+      VpcId: !Ref VpcId
+      AvailabilityZone: us-east-1a
+      CidrBlock: 10.0.0.0/24
+      Tags:
+        - Key: Environment
+          Value: staging
+  Subnet1:
+    Type: AWS::EC2::Subnet
+    Properties:
+      VpcId: !Ref VpcId
+      AvailabilityZone: us-east-1b
+      CidrBlock: 10.0.1.0/24
+      Tags:
+        - Key: Environment
+          Value: staging
+`, template.Body())
+
+	_, err = ts.Fetch("Subnets", map[string]interface{}{"Subnets": []interface{}{}})
+	assert.Nil(t, err)
+
+	_, err = ts.Fetch("Subnets", nil)
+	assert.EqualError(t, err, `unable to render template ../test/stacker/templates/Subnets.yml.tmpl: template: Subnets.yml.tmpl:7:25: executing "Subnets.yml.tmpl" at <.Subnets>: map has no entry for key "Subnets"`)
+}
+
+func TestTemplateStoreFetchIncludes(t *testing.T) {
+	ts := newTemplateStore(TestTemplatesDir)
+
+	template, err := ts.Fetch("Service", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Name"}, template.Parameters())
+	assert.Equal(t, `AWSTemplateFormatVersion: '2010-09-09'
+Parameters:
+  Name:
+    Type: String
+Resources:
+  LogGroup:
+    Type: AWS::Logs::LogGroup
+    Properties:
+      LogGroupName: !Sub /ecs/${Name}
+      RetentionInDays: 30
+  AlarmTopic:
+    Type: AWS::SNS::Topic
+  HighCPU:
+    Type: AWS::CloudWatch::Alarm
+    Properties:
+      AlarmDescription: CPU is high
+      Namespace: AWS/ECS
+      MetricName: CPUUtilization
+      Statistic: Average
+      Period: 60
+      EvaluationPeriods: 5
+      Threshold: 90
+      ComparisonOperator: GreaterThanThreshold
+      AlarmActions:
+        - !Ref AlarmTopic
+  Role:
+    Type: AWS::IAM::Role
+    Properties:
+      AssumeRolePolicyDocument:
+        Statement:
+          - Effect: Allow
+            Principal:
+              Service: ecs-tasks.amazonaws.com
+            Action: sts:AssumeRole
+      Policies:
+        - PolicyName: logging
+          PolicyDocument:
+            Statement:
+              - {"Effect": "Allow", "Action": ["logs:CreateLogStream", "logs:PutLogEvents"], "Resource": "*"}
+`, template.Body())
+}
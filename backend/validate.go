@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+// TemplateParameter describes the constraints a template declares for one of
+// its parameters
+type TemplateParameter struct {
+	Type           string
+	HasDefault     bool
+	NoEcho         bool
+	AllowedValues  []string
+	AllowedPattern string
+	MinLength      *float64
+	MaxLength      *float64
+	MinValue       *float64
+	MaxValue       *float64
+}
+
+func parseTemplateParameter(v interface{}) (*TemplateParameter, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping, got `%v`", v)
+	}
+
+	tp := &TemplateParameter{
+		NoEcho: fmt.Sprint(m["NoEcho"]) == "true",
+	}
+
+	if t, ok := m["Type"]; ok {
+		tp.Type = fmt.Sprint(t)
+	}
+
+	if p, ok := m["AllowedPattern"]; ok {
+		tp.AllowedPattern = fmt.Sprint(p)
+	}
+
+	_, tp.HasDefault = m["Default"]
+
+	if av, ok := m["AllowedValues"]; ok {
+		values, ok := av.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected AllowedValues to be a list, got `%v`", av)
+		}
+		for _, v := range values {
+			tp.AllowedValues = append(tp.AllowedValues, fmt.Sprint(v))
+		}
+	}
+
+	for name, dst := range map[string]**float64{
+		"MinLength": &tp.MinLength,
+		"MaxLength": &tp.MaxLength,
+		"MinValue":  &tp.MinValue,
+		"MaxValue":  &tp.MaxValue,
+	} {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected %s to be a number, got `%v`", name, v)
+		}
+		*dst = &n
+	}
+
+	return tp, nil
+}
+
+// validType returns whether the parameter's type is one cloudformation accepts
+func (tp *TemplateParameter) validType() bool {
+	switch {
+	case tp.Type == "String", tp.Type == "Number", tp.Type == "CommaDelimitedList", tp.Type == "List<Number>":
+		return true
+	case strings.HasPrefix(tp.Type, "AWS::"), strings.HasPrefix(tp.Type, "List<AWS::"):
+		return true
+	}
+	return false
+}
+
+// validate checks a value against the parameter's constraints. Each item of a
+// list parameter is checked individually.
+func (tp *TemplateParameter) validate(value string) error {
+	values := []string{value}
+	if tp.Type == "CommaDelimitedList" || strings.HasPrefix(tp.Type, "List<") {
+		values = strings.Split(value, ",")
+	}
+
+	numeric := tp.Type == "Number" || tp.Type == "List<Number>"
+
+	for _, v := range values {
+		if numeric {
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return errors.New("is not a number")
+			}
+			if tp.MinValue != nil && n < *tp.MinValue {
+				return fmt.Errorf("is less than the minimum value of %v", *tp.MinValue)
+			}
+			if tp.MaxValue != nil && n > *tp.MaxValue {
+				return fmt.Errorf("is greater than the maximum value of %v", *tp.MaxValue)
+			}
+		}
+
+		if len(tp.AllowedValues) > 0 && !contains(tp.AllowedValues, v) {
+			return fmt.Errorf("is not one of the allowed values: %s", strings.Join(tp.AllowedValues, ", "))
+		}
+
+		if tp.AllowedPattern != "" {
+			re, err := regexp.Compile("^(?:" + tp.AllowedPattern + ")$")
+			if err != nil {
+				return fmt.Errorf("cannot be checked against the pattern `%s`: %s", tp.AllowedPattern, err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("does not match the pattern `%s`", tp.AllowedPattern)
+			}
+		}
+
+		length := float64(utf8.RuneCountInString(v))
+		if tp.MinLength != nil && length < *tp.MinLength {
+			return fmt.Errorf("is shorter than the minimum length of %v", *tp.MinLength)
+		}
+		if tp.MaxLength != nil && length > *tp.MaxLength {
+			return fmt.Errorf("is longer than the maximum length of %v", *tp.MaxLength)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the stack's parameters against those declared by its
+// template: every parameter without a default must be supplied, and every
+// resolved value must satisfy the parameter's constraints. Parameters which
+// are configured but not declared by the template are returned as warnings.
+func (s *stack) Validate() ([]string, error) {
+	warnings := make([]string, 0)
+	for _, k := range s.unusedParameters {
+		warnings = append(warnings, fmt.Sprintf("parameter `%s` is not declared by template `%s`", k, s.templateName))
+	}
+
+	errs := make([]error, 0)
+	for _, k := range s.template.Parameters() {
+		tp := s.template.Parameter(k)
+		if !tp.validType() {
+			errs = append(errs, fmt.Errorf("parameter `%s` has unknown type `%s`", k, tp.Type))
+		}
+		if _, ok := s.rawParameters[k]; !ok && !tp.HasDefault {
+			errs = append(errs, fmt.Errorf("parameter `%s` is required by template `%s`", k, s.templateName))
+		}
+	}
+
+	if len(errs) > 0 {
+		return warnings, combineErrors(errs)
+	}
+
+	params, err := s.Params()
+	if err != nil {
+		return warnings, err
+	}
+
+	for _, p := range params {
+		tp := s.template.Parameter(p.Key())
+		if tp == nil || p.UsePrevious() {
+			continue
+		}
+
+		if err := tp.validate(p.Value()); err != nil {
+			errs = append(errs, parameterError(p, tp, err))
+		}
+	}
+
+	return warnings, combineErrors(errs)
+}
+
+// parameterError describes an invalid parameter value, leaving out values
+// which are sensitive or hidden by the template
+func parameterError(p stacker.StackParam, tp *TemplateParameter, err error) error {
+	if p.Sensitive() || tp.NoEcho {
+		return fmt.Errorf("parameter `%s` value %s", p.Key(), err)
+	}
+	return fmt.Errorf("parameter `%s` value `%s` %s", p.Key(), p.Value(), err)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateParameterValidate(t *testing.T) {
+	one, three, ten := 1.0, 3.0, 10.0
+
+	cases := []struct {
+		tp    TemplateParameter
+		value string
+
+		expected string
+	}{
+		{TemplateParameter{Type: "String"}, "anything", ""},
+		{TemplateParameter{Type: "String", AllowedValues: []string{"a", "b"}}, "b", ""},
+		{TemplateParameter{Type: "String", AllowedValues: []string{"a", "b"}}, "c", "is not one of the allowed values: a, b"},
+		{TemplateParameter{Type: "String", AllowedPattern: "[a-z]+"}, "abc", ""},
+		{TemplateParameter{Type: "String", AllowedPattern: "[a-z]+"}, "abc1", "does not match the pattern `[a-z]+`"},
+		{TemplateParameter{Type: "String", AllowedPattern: "(?!a)"}, "abc", "cannot be checked against the pattern `(?!a)`: error parsing regexp: invalid or unsupported Perl syntax: `(?!`"},
+		{TemplateParameter{Type: "String", MinLength: &three}, "ab", "is shorter than the minimum length of 3"},
+		{TemplateParameter{Type: "String", MaxLength: &three}, "abcd", "is longer than the maximum length of 3"},
+		{TemplateParameter{Type: "Number", MinValue: &one, MaxValue: &ten}, "5", ""},
+		{TemplateParameter{Type: "Number"}, "five", "is not a number"},
+		{TemplateParameter{Type: "Number", MinValue: &one}, "0.5", "is less than the minimum value of 1"},
+		{TemplateParameter{Type: "Number", MaxValue: &ten}, "11", "is greater than the maximum value of 10"},
+		{TemplateParameter{Type: "List<Number>", MaxValue: &ten}, "1,2,3", ""},
+		{TemplateParameter{Type: "List<Number>", MaxValue: &ten}, "1,20", "is greater than the maximum value of 10"},
+		{TemplateParameter{Type: "CommaDelimitedList", AllowedValues: []string{"a", "b"}}, "a,b", ""},
+		{TemplateParameter{Type: "CommaDelimitedList", AllowedValues: []string{"a", "b"}}, "a,c", "is not one of the allowed values: a, b"},
+	}
+
+	for _, c := range cases {
+		err := c.tp.validate(c.value)
+
+		if c.expected == "" {
+			assert.Nil(t, err, c.value)
+		} else {
+			assert.EqualError(t, err, c.expected)
+		}
+	}
+}
+
+func TestStackValidate(t *testing.T) {
+	ts := newTemplateStore(TestTemplatesDir)
+	tmpl, _ := ts.Fetch("Database", nil)
+
+	pr := NewParamsResolver()
+	pr.Add("Previous", ResolvePrevious)
+
+	cases := []struct {
+		params RawParams
+		unused []string
+
+		warnings []string
+		expected string
+	}{
+		{
+			RawParams{"Name": "orders", "Storage": 100, "Password": "hunter22", "SubnetIds": []string{"a", "b"}},
+			nil, []string{}, "",
+		},
+		{
+			RawParams{"Name": "orders", "Storage": 100, "Password": map[string]interface{}{"Previous": true}, "SubnetIds": "a"},
+			[]string{"Unused"}, []string{"parameter `Unused` is not declared by template `Database`"}, "",
+		},
+		{
+			RawParams{"Name": "orders"},
+			nil, []string{},
+			"3 errors occured:\n" +
+				"  parameter `Password` is required by template `Database`\n" +
+				"  parameter `Storage` is required by template `Database`\n" +
+				"  parameter `SubnetIds` is required by template `Database`",
+		},
+		{
+			RawParams{"Name": "Orders", "Engine": "oracle", "Storage": 10, "Password": "x", "SubnetIds": "a"},
+			nil, []string{},
+			"4 errors occured:\n" +
+				"  parameter `Engine` value `oracle` is not one of the allowed values: mysql, postgres\n" +
+				"  parameter `Name` value `Orders` does not match the pattern `[a-z][a-z0-9]*`\n" +
+				"  parameter `Password` value is shorter than the minimum length of 8\n" +
+				"  parameter `Storage` value `10` is less than the minimum value of 20",
+		},
+		{
+			RawParams{"Name": "orders", "Storage": 100, "Password": "correcthorse", "SubnetIds": "a", "Engine": "mysql"},
+			nil, []string{}, "",
+		},
+	}
+
+	for _, c := range cases {
+		s := &stack{
+			name:             "Orders",
+			templateName:     "Database",
+			template:         tmpl,
+			rawParameters:    c.params,
+			unusedParameters: c.unused,
+			resolver:         pr,
+		}
+
+		warnings, err := s.Validate()
+
+		assert.Equal(t, c.warnings, warnings)
+		if c.expected == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, c.expected)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package client
 import (
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"path"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -29,9 +33,17 @@ func (s StackInfoList) Less(i, j int) bool {
 	return s[i].Name < s[j].Name
 }
 
+// MaxTemplateBodySize is the largest template cloudformation accepts inline.
+// Larger templates must be uploaded to an artifact bucket.
+const MaxTemplateBodySize = 51200
+
+// NestedStackType is the resource type of stacks nested within a template
+const NestedStackType = "AWS::CloudFormation::Stack"
+
 // Client performs Cloudformation actions with the native Stack interface
 type Client struct {
-	cf CloudformationClient
+	cf       CloudformationClient
+	uploader Uploader
 }
 
 // New returns a new Client given a CloudformationClient
@@ -39,6 +51,12 @@ func New(cf CloudformationClient) *Client {
 	return &Client{cf: cf}
 }
 
+// NewWithUploader returns a new Client which uploads templates to a stack's
+// artifact bucket using the provided Uploader
+func NewWithUploader(cf CloudformationClient, u Uploader) *Client {
+	return &Client{cf: cf, uploader: u}
+}
+
 func (c *Client) ListStacks() ([]*StackInfo, error) {
 	stackInfos := []*StackInfo{}
 
@@ -68,6 +86,7 @@ func (c *Client) ListStacks() ([]*StackInfo, error) {
 				LastUpdatedTime: *s.CreationTime,
 				Params:          StackParamInfos{},
 				Outputs:         StackOutputInfos{},
+				Tags:            StackTagInfos{},
 			}
 
 			if s.LastUpdatedTime != nil {
@@ -91,6 +110,24 @@ func (c *Client) ListStacks() ([]*StackInfo, error) {
 	return stackInfos, nil
 }
 
+// ListExports returns the value of every export within the region, keyed by
+// export name
+func (c *Client) ListExports() (map[string]string, error) {
+	exports := make(map[string]string)
+
+	err := c.cf.ListExportsPages(&cf.ListExportsInput{}, func(output *cf.ListExportsOutput, last bool) bool {
+		for _, e := range output.Exports {
+			exports[deref(e.Name)] = deref(e.Value)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list exports")
+	}
+
+	return exports, nil
+}
+
 // Exists checks the existence of a stack provided its name
 func (c *Client) Exists(stackName string) (bool, error) {
 	s, err := c.Get(stackName)
@@ -132,6 +169,31 @@ func (c *Client) GetTemplate(stackName string) (string, error) {
 	return "", nil
 }
 
+// GetStackPolicy retrieves a stack's policy, returning an empty string when
+// the stack has no policy
+func (c *Client) GetStackPolicy(stackName string) (string, error) {
+	output, err := c.cf.GetStackPolicy(&cf.GetStackPolicyInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "unable to fetch stack policy")
+	}
+
+	if output.StackPolicyBody != nil {
+		return *output.StackPolicyBody, nil
+	}
+	return "", nil
+}
+
+// SetStackPolicy replaces a stack's policy
+func (c *Client) SetStackPolicy(stackName string, policy string) error {
+	_, err := c.cf.SetStackPolicy(&cf.SetStackPolicyInput{
+		StackName:       aws.String(stackName),
+		StackPolicyBody: aws.String(policy),
+	})
+	return errors.Wrap(err, "unable to set stack policy")
+}
+
 // GetChangeSets returns the pending, uncommitted changesets for a stack
 func (c *Client) GetChangeSets(stackName string) (PendingChangeSets, error) {
 	output, err := c.cf.ListChangeSets(&cf.ListChangeSetsInput{
@@ -146,15 +208,37 @@ func (c *Client) GetChangeSets(stackName string) (PendingChangeSets, error) {
 
 // GetChangeSet returns information about a pending changeset
 func (c *Client) GetChangeSet(stackName string, changeSetName string) (*ChangeSetInfo, error) {
-	output, err := c.cf.DescribeChangeSet(&cf.DescribeChangeSetInput{
+	return c.getChangeSet(&cf.DescribeChangeSetInput{
 		StackName:     aws.String(stackName),
 		ChangeSetName: aws.String(changeSetName),
 	})
+}
+
+// getChangeSet describes a changeset along with the changesets of the nested
+// stacks it changes
+func (c *Client) getChangeSet(input *cf.DescribeChangeSetInput) (*ChangeSetInfo, error) {
+	output, err := c.cf.DescribeChangeSet(input)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch changeset")
 	}
 
-	return newChangeSetInfo(output), nil
+	csi := newChangeSetInfo(output)
+	for _, rc := range csi.Changes {
+		if rc.ChangeSetID == "" {
+			continue
+		}
+
+		// Nested changesets are addressed by their ARN alone
+		nested, err := c.getChangeSet(&cf.DescribeChangeSetInput{
+			ChangeSetName: aws.String(rc.ChangeSetID),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "nested stack %s", rc.Name)
+		}
+		csi.Nested = append(csi.Nested, nested)
+	}
+
+	return csi, nil
 }
 
 // GetChangeSetTemplate returns the template for a pending changeset
@@ -175,7 +259,8 @@ func (c *Client) GetChangeSetTemplate(stackName string, changeSetName string) (s
 	return "", nil
 }
 
-// GetResources fetches a Stack's resource information
+// GetResources fetches a Stack's resource information, including the
+// resources of nested stacks
 func (c *Client) GetResources(stackName string) (ResourceInfos, error) {
 	output, err := c.cf.DescribeStackResources(&cf.DescribeStackResourcesInput{
 		StackName: aws.String(stackName),
@@ -184,7 +269,18 @@ func (c *Client) GetResources(stackName string) (ResourceInfos, error) {
 		return nil, errors.Wrap(err, "unable to fetch resources")
 	}
 
-	return newResourceInfos(output.StackResources), nil
+	ri := newResourceInfos(output.StackResources)
+	for i, r := range ri {
+		if r.Type != NestedStackType || r.ID == "" {
+			continue
+		}
+
+		if ri[i].Nested, err = c.GetResources(r.ID); err != nil {
+			return nil, errors.Wrapf(err, "nested stack %s", r.Name)
+		}
+	}
+
+	return ri, nil
 }
 
 // GetEvents returns the latest events for a stack
@@ -228,6 +324,15 @@ func (c *Client) Commit(stackName string, changeSetName string) error {
 	return errors.Wrap(err, "unable to commit changeset")
 }
 
+// SetTerminationProtection enables or disables termination protection on a stack
+func (c *Client) SetTerminationProtection(stackName string, enabled bool) error {
+	_, err := c.cf.UpdateTerminationProtection(&cf.UpdateTerminationProtectionInput{
+		StackName:                   aws.String(stackName),
+		EnableTerminationProtection: aws.Bool(enabled),
+	})
+	return errors.Wrap(err, "unable to update termination protection")
+}
+
 // Delete deletes a stack
 func (c *Client) Delete(name string) error {
 	_, err := c.cf.DeleteStack(&cf.DeleteStackInput{
@@ -341,12 +446,25 @@ func (c *Client) createChangeSet(typ string, changeSetName string, s stacker.Sta
 		return nil, err
 	}
 
+	if typ == cf.ChangeSetTypeCreate {
+		for _, p := range params {
+			if p.UsePrevious() {
+				return nil, fmt.Errorf("parameter `%s` cannot use its previous value when creating stack `%s`", p.Key(), s.Name())
+			}
+		}
+	}
+
+	// Changes to nested stacks are described by changesets of their own
 	cs := &cf.CreateChangeSetInput{
-		ChangeSetName: aws.String(changeSetName),
-		ChangeSetType: aws.String(typ),
-		StackName:     aws.String(s.Name()),
-		TemplateBody:  aws.String(s.TemplateBody()),
-		Parameters:    cfParams(params),
+		ChangeSetName:       aws.String(changeSetName),
+		ChangeSetType:       aws.String(typ),
+		StackName:           aws.String(s.Name()),
+		Parameters:          cfParams(params),
+		IncludeNestedStacks: aws.Bool(true),
+	}
+
+	if err := c.setTemplate(cs, s); err != nil {
+		return nil, err
 	}
 
 	if len(s.Capabilities()) > 0 {
@@ -357,6 +475,24 @@ func (c *Client) createChangeSet(typ string, changeSetName string, s stacker.Sta
 		cs.Capabilities = caps
 	}
 
+	// Omitted tags leave those of a deployed stack in place, so declared tags
+	// are always sent, even when there are none, to remove stale tags
+	if s.Tags() != nil {
+		cs.Tags = cfTags(s.Tags())
+	}
+
+	if s.RoleARN() != "" {
+		cs.RoleARN = aws.String(s.RoleARN())
+	}
+
+	if len(s.NotificationARNs()) > 0 {
+		cs.NotificationARNs = aws.StringSlice(s.NotificationARNs())
+	}
+
+	if rc := s.RollbackConfiguration(); rc != nil {
+		cs.RollbackConfiguration = cfRollbackConfiguration(rc)
+	}
+
 	if _, err := c.cf.CreateChangeSet(cs); err != nil {
 		return nil, errors.Wrap(err, "unable to create changeset")
 	}
@@ -364,6 +500,62 @@ func (c *Client) createChangeSet(typ string, changeSetName string, s stacker.Sta
 	return c.GetChangeSet(s.Name(), changeSetName)
 }
 
+// setTemplate sends a stack's template inline, or uploads it to the stack's
+// artifact bucket when it's too large or the bucket requires it
+func (c *Client) setTemplate(cs *cf.CreateChangeSetInput, s stacker.Stack) error {
+	body, err := s.TemplateBody()
+	if err != nil {
+		return errors.Wrap(err, "unable to package template")
+	}
+
+	bucket := s.ArtifactBucket()
+
+	if bucket == nil || (!bucket.Always && len(body) <= MaxTemplateBodySize) {
+		if len(body) > MaxTemplateBodySize {
+			return fmt.Errorf(
+				"template for stack `%s` is %d bytes, larger than the %d bytes allowed inline; configure an artifact_bucket to upload it",
+				s.Name(), len(body), MaxTemplateBodySize,
+			)
+		}
+		cs.TemplateBody = aws.String(body)
+		return nil
+	}
+
+	if c.uploader == nil {
+		return fmt.Errorf("unable to upload template for stack `%s` without an uploader", s.Name())
+	}
+
+	url, err := c.uploader.Upload(bucket.Name, templateKey(bucket.Prefix, body), []byte(body))
+	if err != nil {
+		return errors.Wrap(err, "unable to upload template")
+	}
+
+	cs.TemplateURL = aws.String(url)
+	return nil
+}
+
+// templateKey returns an S3 key addressing a template by its content
+func templateKey(prefix string, body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return path.Join(prefix, "templates", hex.EncodeToString(sum[:])+".template")
+}
+
+func cfRollbackConfiguration(rc *stacker.RollbackConfiguration) *cf.RollbackConfiguration {
+	triggers := make([]*cf.RollbackTrigger, len(rc.AlarmARNs))
+	for i, arn := range rc.AlarmARNs {
+		triggers[i] = &cf.RollbackTrigger{
+			Arn:  aws.String(arn),
+			Type: aws.String("AWS::CloudWatch::Alarm"),
+		}
+	}
+
+	config := &cf.RollbackConfiguration{RollbackTriggers: triggers}
+	if rc.MonitoringTimeInMinutes > 0 {
+		config.MonitoringTimeInMinutes = aws.Int64(rc.MonitoringTimeInMinutes)
+	}
+	return config
+}
+
 func cfParams(sp stacker.StackParams) []*cf.Parameter {
 	params := make([]*cf.Parameter, len(sp))
 	for i, p := range sp {
@@ -378,6 +570,21 @@ func cfParams(sp stacker.StackParams) []*cf.Parameter {
 	return params
 }
 
+// cfTags converts a map of tags into a list sorted by key
+func cfTags(t map[string]string) []*cf.Tag {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]*cf.Tag, len(keys))
+	for i, k := range keys {
+		tags[i] = &cf.Tag{Key: aws.String(k), Value: aws.String(t[k])}
+	}
+	return tags
+}
+
 // changeSetName provides a random name
 func changeSetName() (string, error) {
 	b := make([]byte, 16)
@@ -438,5 +645,4 @@ func (c *Client) NotifyUntilComplete(name string, f func(s *StackInfo)) error {
 
 		time.Sleep(5 * time.Second)
 	}
-	return nil
 }
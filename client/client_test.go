@@ -1,13 +1,17 @@
 package client
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/fatih/color"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
@@ -15,27 +19,50 @@ import (
 )
 
 type fakeStack struct {
-	name         string
-	templateBody string
-	params       []stacker.StackParam
-	capabilities []string
+	name                  string
+	templateBody          string
+	params                []stacker.StackParam
+	capabilities          []string
+	tags                  map[string]string
+	roleARN               string
+	notificationARNs      []string
+	rollbackConfiguration *stacker.RollbackConfiguration
+	artifactBucket        *stacker.ArtifactBucket
 }
 
 func (s *fakeStack) Name() string                          { return s.name }
 func (s *fakeStack) Region() string                        { return "" }
-func (s *fakeStack) TemplateBody() string                  { return s.templateBody }
+func (s *fakeStack) Profile() string                       { return "" }
+func (s *fakeStack) TemplateBody() (string, error)         { return s.templateBody, nil }
 func (s *fakeStack) Params() ([]stacker.StackParam, error) { return s.params, nil }
+func (s *fakeStack) SensitiveParams() []string             { return nil }
+func (s *fakeStack) PreviousParams() []string              { return nil }
 func (s *fakeStack) Capabilities() []string                { return s.capabilities }
+func (s *fakeStack) Tags() map[string]string               { return s.tags }
+func (s *fakeStack) RoleARN() string                       { return s.roleARN }
+func (s *fakeStack) NotificationARNs() []string            { return s.notificationARNs }
+func (s *fakeStack) TerminationProtection() *bool          { return nil }
+func (s *fakeStack) StackPolicy() string                   { return "" }
+func (s *fakeStack) Dependencies() []stacker.StackRef      { return nil }
+func (s *fakeStack) Validate() ([]string, error)           { return nil, nil }
+func (s *fakeStack) RollbackConfiguration() *stacker.RollbackConfiguration {
+	return s.rollbackConfiguration
+}
+func (s *fakeStack) ArtifactBucket() *stacker.ArtifactBucket {
+	return s.artifactBucket
+}
 
 type fakeStackParam struct {
 	key         string
 	value       string
 	usePrevious bool
+	sensitive   bool
 }
 
 func (s *fakeStackParam) Key() string       { return s.key }
 func (s *fakeStackParam) Value() string     { return s.value }
 func (s *fakeStackParam) UsePrevious() bool { return s.usePrevious }
+func (s *fakeStackParam) Sensitive() bool   { return s.sensitive }
 
 type mockCloudformation struct {
 	CloudformationClient
@@ -84,6 +111,35 @@ func (c *mockCloudformation) DeleteStack(input *cloudformation.DeleteStackInput)
 	return so, r.Error(1)
 }
 
+func (c *mockCloudformation) UpdateTerminationProtection(input *cloudformation.UpdateTerminationProtectionInput) (*cloudformation.UpdateTerminationProtectionOutput, error) {
+	r := c.Called(input)
+	so, _ := r.Get(0).(*cloudformation.UpdateTerminationProtectionOutput)
+	return so, r.Error(1)
+}
+
+func (c *mockCloudformation) GetStackPolicy(input *cloudformation.GetStackPolicyInput) (*cloudformation.GetStackPolicyOutput, error) {
+	r := c.Called(input)
+	so, _ := r.Get(0).(*cloudformation.GetStackPolicyOutput)
+	return so, r.Error(1)
+}
+
+func (c *mockCloudformation) SetStackPolicy(input *cloudformation.SetStackPolicyInput) (*cloudformation.SetStackPolicyOutput, error) {
+	r := c.Called(input)
+	so, _ := r.Get(0).(*cloudformation.SetStackPolicyOutput)
+	return so, r.Error(1)
+}
+
+func (c *mockCloudformation) ListExportsPages(input *cloudformation.ListExportsInput, fn func(*cloudformation.ListExportsOutput, bool) bool) error {
+	r := c.Called(input)
+	pages, _ := r.Get(0).([]*cloudformation.ListExportsOutput)
+	for i, p := range pages {
+		if !fn(p, i == len(pages)-1) {
+			break
+		}
+	}
+	return r.Error(1)
+}
+
 func TestGet(t *testing.T) {
 	var (
 		cf          = &mockCloudformation{}
@@ -117,6 +173,9 @@ func TestGet(t *testing.T) {
 							{OutputKey: aws.String("outkey1"), OutputValue: aws.String("outvalue1")},
 							{OutputKey: aws.String("outkey2"), OutputValue: aws.String("outvalue2")},
 						},
+						Tags: []*cloudformation.Tag{
+							{Key: aws.String("Team"), Value: aws.String("platform")},
+						},
 					},
 				},
 			},
@@ -135,6 +194,9 @@ func TestGet(t *testing.T) {
 					{Key: "outkey1", Value: "outvalue1"},
 					{Key: "outkey2", Value: "outvalue2"},
 				},
+				Tags: StackTagInfos{
+					{Key: "Team", Value: "platform"},
+				},
 			},
 			false,
 		},
@@ -342,6 +404,7 @@ func TestGetChangeSet(t *testing.T) {
 				StackName:       stackName,
 				Changes:         ResourceChanges{},
 				Params:          StackParamInfos{},
+				Tags:            StackTagInfos{},
 			},
 			false,
 		},
@@ -450,6 +513,140 @@ func TestGetResources(t *testing.T) {
 	}
 }
 
+func TestGetChangeSetNested(t *testing.T) {
+	var (
+		cf        = &mockCloudformation{}
+		c         = New(cf)
+		stackName = "Foo-Stack"
+		changeSet = "cs-12345678"
+		nestedID  = "arn:aws:cloudformation:us-east-1:123456789012:changeSet/Foo-Stack-Queues/abc"
+		created   = time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC)
+	)
+
+	cf.On("DescribeChangeSet", &cloudformation.DescribeChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String(changeSet),
+	}).Once().Return(&cloudformation.DescribeChangeSetOutput{
+		ChangeSetId:     aws.String("id"),
+		ChangeSetName:   aws.String(changeSet),
+		StackName:       aws.String(stackName),
+		Status:          aws.String("CREATE_COMPLETE"),
+		ExecutionStatus: aws.String("AVAILABLE"),
+		CreationTime:    aws.Time(created),
+		Changes: []*cloudformation.Change{
+			{ResourceChange: &cloudformation.ResourceChange{
+				Action:             aws.String("Modify"),
+				LogicalResourceId:  aws.String("Queues"),
+				PhysicalResourceId: aws.String("Foo-Stack-Queues"),
+				ResourceType:       aws.String(NestedStackType),
+				Replacement:        aws.String("False"),
+				ChangeSetId:        aws.String(nestedID),
+			}},
+		},
+	}, nil)
+
+	cf.On("DescribeChangeSet", &cloudformation.DescribeChangeSetInput{
+		ChangeSetName: aws.String(nestedID),
+	}).Once().Return(&cloudformation.DescribeChangeSetOutput{
+		ChangeSetId:     aws.String(nestedID),
+		StackName:       aws.String("Foo-Stack-Queues"),
+		Status:          aws.String("CREATE_COMPLETE"),
+		ExecutionStatus: aws.String("AVAILABLE"),
+		Changes: []*cloudformation.Change{
+			{ResourceChange: &cloudformation.ResourceChange{
+				Action:            aws.String("Remove"),
+				LogicalResourceId: aws.String("Queue"),
+				ResourceType:      aws.String("AWS::SQS::Queue"),
+			}},
+		},
+	}, nil)
+
+	cs, err := c.GetChangeSet(stackName, changeSet)
+
+	assert.Nil(t, err)
+	assert.Len(t, cs.Nested, 1)
+	assert.Equal(t, "Foo-Stack-Queues", cs.Nested[0].StackName)
+	assert.Equal(t, []string{"Queues", "Queue"}, []string{cs.AllChanges()[0].Name, cs.AllChanges()[1].Name})
+
+	color.NoColor = true
+	defer func() { color.NoColor = false }()
+
+	assert.Equal(t, `Stack: Foo-Stack
+Changeset: cs-12345678
+  Status: CREATE_COMPLETE
+  Execution Status: AVAILABLE
+  Created At: 2020-11-20 00:00:00 +0000 UTC
+  Resources
+    AWS::CloudFormation::Stack: Queues (Foo-Stack-Queues)
+      Action: Modify
+      Replacement: false
+      Resources
+        AWS::SQS::Queue: Queue
+          Action: Remove
+          Replacement: false
+`, cs.String())
+}
+
+func TestGetResourcesNested(t *testing.T) {
+	var (
+		cf       = &mockCloudformation{}
+		c        = New(cf)
+		now      = time.Now()
+		nestedID = "arn:aws:cloudformation:us-east-1:123456789012:stack/Foo-Stack-Queues/abc"
+	)
+
+	cf.On("DescribeStackResources", &cloudformation.DescribeStackResourcesInput{
+		StackName: aws.String("Foo-Stack"),
+	}).Once().Return(&cloudformation.DescribeStackResourcesOutput{
+		StackResources: []*cloudformation.StackResource{
+			{
+				PhysicalResourceId: aws.String(nestedID),
+				LogicalResourceId:  aws.String("Queues"),
+				ResourceStatus:     aws.String("UPDATE_COMPLETE"),
+				ResourceType:       aws.String(NestedStackType),
+				Timestamp:          aws.Time(now),
+			},
+		},
+	}, nil)
+
+	cf.On("DescribeStackResources", &cloudformation.DescribeStackResourcesInput{
+		StackName: aws.String(nestedID),
+	}).Once().Return(&cloudformation.DescribeStackResourcesOutput{
+		StackResources: []*cloudformation.StackResource{
+			{
+				PhysicalResourceId: aws.String("https://sqs.us-east-1.amazonaws.com/123456789012/queue"),
+				LogicalResourceId:  aws.String("Queue"),
+				ResourceStatus:     aws.String("CREATE_COMPLETE"),
+				ResourceType:       aws.String("AWS::SQS::Queue"),
+				Timestamp:          aws.Time(now),
+			},
+		},
+	}, nil)
+
+	ri, err := c.GetResources("Foo-Stack")
+
+	assert.Nil(t, err)
+	assert.Equal(t, ResourceInfos{
+		{
+			ID:          nestedID,
+			Name:        "Queues",
+			Status:      "UPDATE_COMPLETE",
+			Type:        NestedStackType,
+			UpdatedTime: now,
+			Nested: ResourceInfos{
+				{
+					ID:          "https://sqs.us-east-1.amazonaws.com/123456789012/queue",
+					Name:        "Queue",
+					Status:      "CREATE_COMPLETE",
+					Type:        "AWS::SQS::Queue",
+					UpdatedTime: now,
+				},
+			},
+		},
+	}, ri)
+	assert.Len(t, ri.rows(""), 2)
+}
+
 func TestCreateChangeSet(t *testing.T) {
 	var (
 		cf        = &mockCloudformation{}
@@ -500,6 +697,7 @@ func TestCreateChangeSet(t *testing.T) {
 				StackName:       stackName,
 				Changes:         ResourceChanges{},
 				Params:          StackParamInfos{},
+				Tags:            StackTagInfos{},
 			},
 			false,
 		},
@@ -539,13 +737,15 @@ func TestCreateChangeSet(t *testing.T) {
 
 	for _, s := range scenarios {
 		p, _ := s.stack.Params()
+		body, _ := s.stack.TemplateBody()
 
 		cf.On("CreateChangeSet", &cloudformation.CreateChangeSetInput{
-			ChangeSetName: aws.String(changeSet),
-			ChangeSetType: aws.String(cloudformation.ChangeSetTypeCreate),
-			StackName:     aws.String(s.stack.Name()),
-			TemplateBody:  aws.String(s.stack.TemplateBody()),
-			Parameters:    cfParams(p),
+			ChangeSetName:       aws.String(changeSet),
+			ChangeSetType:       aws.String(cloudformation.ChangeSetTypeCreate),
+			StackName:           aws.String(s.stack.Name()),
+			TemplateBody:        aws.String(body),
+			Parameters:          cfParams(p),
+			IncludeNestedStacks: aws.Bool(true),
 		}).Once().Return(nil, s.createErr)
 
 		if s.createErr == nil {
@@ -594,3 +794,391 @@ func TestDelete(t *testing.T) {
 		}
 	}
 }
+
+func TestCfTags(t *testing.T) {
+	tags := cfTags(map[string]string{
+		"Team":       "platform",
+		"CostCenter": "1234",
+		"Env":        "production",
+	})
+
+	expected := []*cloudformation.Tag{
+		{Key: aws.String("CostCenter"), Value: aws.String("1234")},
+		{Key: aws.String("Env"), Value: aws.String("production")},
+		{Key: aws.String("Team"), Value: aws.String("platform")},
+	}
+
+	assert.Equal(t, expected, tags)
+}
+
+func TestCreateChangeSetOptions(t *testing.T) {
+	var (
+		cf        = &mockCloudformation{}
+		c         = New(cf)
+		changeSet = "cs-12345678"
+		stackName = "Foo-Stack"
+		stack     = &fakeStack{
+			name:             stackName,
+			templateBody:     "the-template",
+			roleARN:          "arn:aws:iam::123456789012:role/cloudformation",
+			notificationARNs: []string{"arn:aws:sns:us-east-1:123456789012:stack-events"},
+			rollbackConfiguration: &stacker.RollbackConfiguration{
+				MonitoringTimeInMinutes: 10,
+				AlarmARNs:               []string{"arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors"},
+			},
+		}
+	)
+
+	cf.On("CreateChangeSet", &cloudformation.CreateChangeSetInput{
+		ChangeSetName:       aws.String(changeSet),
+		ChangeSetType:       aws.String(cloudformation.ChangeSetTypeUpdate),
+		StackName:           aws.String(stackName),
+		TemplateBody:        aws.String("the-template"),
+		Parameters:          []*cloudformation.Parameter{},
+		IncludeNestedStacks: aws.Bool(true),
+		RoleARN:             aws.String("arn:aws:iam::123456789012:role/cloudformation"),
+		NotificationARNs:    []*string{aws.String("arn:aws:sns:us-east-1:123456789012:stack-events")},
+		RollbackConfiguration: &cloudformation.RollbackConfiguration{
+			MonitoringTimeInMinutes: aws.Int64(10),
+			RollbackTriggers: []*cloudformation.RollbackTrigger{
+				{
+					Arn:  aws.String("arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors"),
+					Type: aws.String("AWS::CloudWatch::Alarm"),
+				},
+			},
+		},
+	}).Once().Return(nil, errors.New("Boom"))
+
+	_, err := c.createChangeSet(cloudformation.ChangeSetTypeUpdate, changeSet, stack)
+
+	assert.NotNil(t, err)
+	cf.AssertExpectations(t)
+}
+
+func TestCreateChangeSetUsePrevious(t *testing.T) {
+	var (
+		cf        = &mockCloudformation{}
+		c         = New(cf)
+		changeSet = "cs-12345678"
+		stackName = "Foo-Stack"
+		stack     = &fakeStack{
+			name:         stackName,
+			templateBody: "the-template",
+			params: []stacker.StackParam{
+				&fakeStackParam{key: "Name", value: "foo"},
+				&fakeStackParam{key: "Password", usePrevious: true},
+			},
+		}
+	)
+
+	cf.On("CreateChangeSet", &cloudformation.CreateChangeSetInput{
+		ChangeSetName: aws.String(changeSet),
+		ChangeSetType: aws.String(cloudformation.ChangeSetTypeUpdate),
+		StackName:     aws.String(stackName),
+		TemplateBody:  aws.String("the-template"),
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("Name"), ParameterValue: aws.String("foo")},
+			{ParameterKey: aws.String("Password"), UsePreviousValue: aws.Bool(true)},
+		},
+		IncludeNestedStacks: aws.Bool(true),
+	}).Once().Return(nil, errors.New("Boom"))
+
+	_, err := c.createChangeSet(cloudformation.ChangeSetTypeUpdate, changeSet, stack)
+
+	assert.EqualError(t, err, "unable to create changeset: Boom")
+
+	_, err = c.createChangeSet(cloudformation.ChangeSetTypeCreate, changeSet, stack)
+
+	assert.EqualError(t, err, "parameter `Password` cannot use its previous value when creating stack `Foo-Stack`")
+	cf.AssertExpectations(t)
+}
+
+func TestCreateChangeSetTags(t *testing.T) {
+	scenarios := []struct {
+		tags     map[string]string
+		expected []*cloudformation.Tag
+	}{
+		// Undeclared tags leave those of the deployed stack in place
+		{nil, nil},
+		// Declared tags replace those of the deployed stack, even when empty
+		{map[string]string{}, []*cloudformation.Tag{}},
+		{map[string]string{"Env": "production"}, []*cloudformation.Tag{
+			{Key: aws.String("Env"), Value: aws.String("production")},
+		}},
+	}
+
+	for _, s := range scenarios {
+		var (
+			cf        = &mockCloudformation{}
+			c         = New(cf)
+			changeSet = "cs-12345678"
+			stack     = &fakeStack{name: "Foo-Stack", templateBody: "the-template", tags: s.tags}
+		)
+
+		cf.On("CreateChangeSet", &cloudformation.CreateChangeSetInput{
+			ChangeSetName:       aws.String(changeSet),
+			ChangeSetType:       aws.String(cloudformation.ChangeSetTypeUpdate),
+			StackName:           aws.String("Foo-Stack"),
+			TemplateBody:        aws.String("the-template"),
+			Parameters:          []*cloudformation.Parameter{},
+			IncludeNestedStacks: aws.Bool(true),
+			Tags:                s.expected,
+		}).Once().Return(nil, errors.New("Boom"))
+
+		_, err := c.createChangeSet(cloudformation.ChangeSetTypeUpdate, changeSet, stack)
+
+		assert.EqualError(t, err, "unable to create changeset: Boom")
+		cf.AssertExpectations(t)
+	}
+}
+
+// fakeUploader stores uploads in memory
+type fakeUploader struct {
+	uploads map[string]string
+}
+
+func (u *fakeUploader) Upload(bucket string, key string, body []byte) (string, error) {
+	url := "https://" + bucket + ".s3.amazonaws.com/" + key
+	u.uploads[url] = string(body)
+	return url, nil
+}
+
+func TestCreateChangeSetTemplateURL(t *testing.T) {
+	var (
+		changeSet = "cs-12345678"
+		large     = strings.Repeat(" ", MaxTemplateBodySize+1)
+		key       = func(body string) string {
+			sum := sha256.Sum256([]byte(body))
+			return hex.EncodeToString(sum[:]) + ".template"
+		}
+	)
+
+	scenarios := []struct {
+		body   string
+		bucket *stacker.ArtifactBucket
+
+		templateBody string
+		templateURL  string
+		err          string
+	}{
+		{"small", nil, "small", "", ""},
+		{"small", &stacker.ArtifactBucket{Name: "artifacts"}, "small", "", ""},
+		{
+			"small", &stacker.ArtifactBucket{Name: "artifacts", Prefix: "stacker", Always: true},
+			"", "https://artifacts.s3.amazonaws.com/stacker/templates/" + key("small"), "",
+		},
+		{
+			large, &stacker.ArtifactBucket{Name: "artifacts"},
+			"", "https://artifacts.s3.amazonaws.com/templates/" + key(large), "",
+		},
+		{
+			large, nil, "", "",
+			"template for stack `Foo-Stack` is 51201 bytes, larger than the 51200 bytes allowed inline; configure an artifact_bucket to upload it",
+		},
+	}
+
+	for _, s := range scenarios {
+		var (
+			cf       = &mockCloudformation{}
+			uploader = &fakeUploader{uploads: make(map[string]string)}
+			c        = NewWithUploader(cf, uploader)
+			stack    = &fakeStack{name: "Foo-Stack", templateBody: s.body, artifactBucket: s.bucket}
+		)
+
+		input := &cloudformation.CreateChangeSetInput{
+			ChangeSetName:       aws.String(changeSet),
+			ChangeSetType:       aws.String(cloudformation.ChangeSetTypeUpdate),
+			StackName:           aws.String("Foo-Stack"),
+			Parameters:          []*cloudformation.Parameter{},
+			IncludeNestedStacks: aws.Bool(true),
+		}
+		if s.templateBody != "" {
+			input.TemplateBody = aws.String(s.templateBody)
+		}
+		if s.templateURL != "" {
+			input.TemplateURL = aws.String(s.templateURL)
+		}
+
+		cf.On("CreateChangeSet", input).Return(nil, errors.New("Boom"))
+
+		_, err := c.createChangeSet(cloudformation.ChangeSetTypeUpdate, changeSet, stack)
+
+		if s.err != "" {
+			assert.EqualError(t, err, s.err)
+			cf.AssertNotCalled(t, "CreateChangeSet", input)
+			continue
+		}
+
+		assert.EqualError(t, err, "unable to create changeset: Boom")
+		cf.AssertExpectations(t)
+
+		if s.templateURL != "" {
+			assert.Equal(t, map[string]string{s.templateURL: s.body}, uploader.uploads)
+		} else {
+			assert.Empty(t, uploader.uploads)
+		}
+	}
+}
+
+func TestSetTerminationProtection(t *testing.T) {
+	var (
+		cf        = &mockCloudformation{}
+		c         = New(cf)
+		stackName = "Foo-Stack"
+	)
+
+	scenarios := []struct {
+		enabled bool
+		err     error
+	}{
+		{true, nil},
+		{false, nil},
+		{true, errors.New("boom")},
+	}
+
+	for _, s := range scenarios {
+		cf.On("UpdateTerminationProtection", &cloudformation.UpdateTerminationProtectionInput{
+			StackName:                   aws.String(stackName),
+			EnableTerminationProtection: aws.Bool(s.enabled),
+		}).Once().Return(&cloudformation.UpdateTerminationProtectionOutput{}, s.err)
+
+		err := c.SetTerminationProtection(stackName, s.enabled)
+
+		if s.err != nil {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestGetStackPolicy(t *testing.T) {
+	var (
+		cf        = &mockCloudformation{}
+		c         = New(cf)
+		stackName = "Foo-Stack"
+		policy    = `{"Statement":[{"Effect":"Deny","Action":"Update:Replace","Principal":"*","Resource":"*"}]}`
+	)
+
+	scenarios := []struct {
+		response *cloudformation.GetStackPolicyOutput
+		err      error
+
+		expected string
+		hasError bool
+	}{
+		{&cloudformation.GetStackPolicyOutput{StackPolicyBody: aws.String(policy)}, nil, policy, false},
+		{&cloudformation.GetStackPolicyOutput{}, nil, "", false},
+		{nil, errors.New("boom"), "", true},
+	}
+
+	for _, s := range scenarios {
+		cf.On("GetStackPolicy", &cloudformation.GetStackPolicyInput{
+			StackName: aws.String(stackName),
+		}).Once().Return(s.response, s.err)
+
+		p, err := c.GetStackPolicy(stackName)
+		assert.Equal(t, s.expected, p)
+
+		if s.hasError {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestSetStackPolicy(t *testing.T) {
+	var (
+		cf        = &mockCloudformation{}
+		c         = New(cf)
+		stackName = "Foo-Stack"
+		policy    = `{"Statement":[{"Effect":"Allow","Action":"Update:*","Principal":"*","Resource":"*"}]}`
+	)
+
+	scenarios := []struct {
+		err error
+	}{
+		{nil},
+		{errors.New("boom")},
+	}
+
+	for _, s := range scenarios {
+		cf.On("SetStackPolicy", &cloudformation.SetStackPolicyInput{
+			StackName:       aws.String(stackName),
+			StackPolicyBody: aws.String(policy),
+		}).Once().Return(&cloudformation.SetStackPolicyOutput{}, s.err)
+
+		err := c.SetStackPolicy(stackName, policy)
+
+		if s.err != nil {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestStackParamInfosMarkSensitive(t *testing.T) {
+	params := StackParamInfos{
+		{Key: "Name", Value: "FooVPC"},
+		{Key: "Password", Value: "hunter2"},
+	}
+
+	params.MarkSensitive(func(key string) bool { return key == "Password" })
+
+	assert.False(t, params[0].Sensitive)
+	assert.True(t, params[1].Sensitive)
+	assert.Equal(t, "FooVPC", params[0].DisplayValue())
+	assert.Equal(t, SensitiveValueMask, params[1].DisplayValue())
+	assert.NotContains(t, params.String(), "hunter2")
+}
+
+func TestListExports(t *testing.T) {
+	var (
+		cf = &mockCloudformation{}
+		c  = New(cf)
+	)
+
+	scenarios := []struct {
+		pages []*cloudformation.ListExportsOutput
+		err   error
+
+		expected map[string]string
+		hasError bool
+	}{
+		{
+			[]*cloudformation.ListExportsOutput{
+				{
+					Exports: []*cloudformation.Export{
+						{Name: aws.String("NetworkVpcId"), Value: aws.String("vpc-123")},
+					},
+					NextToken: aws.String("page-2"),
+				},
+				{
+					Exports: []*cloudformation.Export{
+						{Name: aws.String("NetworkSubnets"), Value: aws.String("subnet-1,subnet-2")},
+					},
+				},
+			},
+			nil,
+			map[string]string{"NetworkVpcId": "vpc-123", "NetworkSubnets": "subnet-1,subnet-2"},
+			false,
+		},
+		{nil, nil, map[string]string{}, false},
+		{nil, errors.New("boom"), nil, true},
+	}
+
+	for _, s := range scenarios {
+		cf.On("ListExportsPages", &cloudformation.ListExportsInput{}).Once().Return(s.pages, s.err)
+
+		exports, err := c.ListExports()
+		assert.Equal(t, s.expected, exports)
+
+		if s.hasError {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package client
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	cf "github.com/aws/aws-sdk-go/service/cloudformation"
 )
 
@@ -17,17 +16,21 @@ type CloudformationClient interface {
 	DescribeStacksRequest(*cf.DescribeStacksInput) (*request.Request, *cf.DescribeStacksOutput)
 	DescribeStackEvents(*cf.DescribeStackEventsInput) (*cf.DescribeStackEventsOutput, error)
 	ExecuteChangeSet(*cf.ExecuteChangeSetInput) (*cf.ExecuteChangeSetOutput, error)
+	GetStackPolicy(*cf.GetStackPolicyInput) (*cf.GetStackPolicyOutput, error)
 	GetTemplate(*cf.GetTemplateInput) (*cf.GetTemplateOutput, error)
 	ListChangeSets(input *cf.ListChangeSetsInput) (*cf.ListChangeSetsOutput, error)
+	ListExportsPages(input *cf.ListExportsInput, fn func(*cf.ListExportsOutput, bool) bool) error
 	ListStacksPages(input *cf.ListStacksInput, fn func(*cf.ListStacksOutput, bool) bool) error
+	SetStackPolicy(*cf.SetStackPolicyInput) (*cf.SetStackPolicyOutput, error)
+	UpdateTerminationProtection(*cf.UpdateTerminationProtectionInput) (*cf.UpdateTerminationProtectionOutput, error)
 	WaitUntilChangeSetCreateCompleteWithContext(ctx aws.Context, input *cf.DescribeChangeSetInput, opts ...request.WaiterOption) error
 }
 
-// NewCloudformationClient creates a new CloudformationClient given a region
-func NewCloudformationClient(region string) CloudformationClient {
-	s, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+// NewCloudformationClientWithProfile creates a new CloudformationClient given a region and profile
+func NewCloudformationClientWithProfile(region string, profile string) (CloudformationClient, error) {
+	s, err := newSession(region, profile)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return cf.New(s)
+	return cf.New(s), nil
 }
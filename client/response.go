@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"time"
 
 	cf "github.com/aws/aws-sdk-go/service/cloudformation"
@@ -88,16 +89,7 @@ func newResourceInfos(resources []*cf.StackResource) ResourceInfos {
 func (ri ResourceInfos) String() string {
 	var buffer bytes.Buffer
 
-	data := make([][]string, len(ri))
-	for i, r := range ri {
-		data[i] = []string{
-			underline(bold(r.Type)),
-			cyan(r.Name),
-			cyan(r.Status),
-			cyan(r.ID),
-			cyan(r.UpdatedTime),
-		}
-	}
+	data := ri.rows("")
 
 	table := tablewriter.NewWriter(&buffer)
 	table.SetColumnSeparator("")
@@ -110,7 +102,25 @@ func (ri ResourceInfos) String() string {
 	return buffer.String()
 }
 
-// ResourceInfo represents a physical AWS resource
+// rows returns a table row for each resource, followed by the rows of any
+// nested stack's resources indented beneath it
+func (ri ResourceInfos) rows(indent string) [][]string {
+	data := make([][]string, 0, len(ri))
+	for _, r := range ri {
+		data = append(data, []string{
+			indent + underline(bold(r.Type)),
+			cyan(r.Name),
+			cyan(r.Status),
+			cyan(r.ID),
+			cyan(r.UpdatedTime),
+		})
+		data = append(data, r.Nested.rows(indent+"  ")...)
+	}
+	return data
+}
+
+// ResourceInfo represents a physical AWS resource. The resources of a nested
+// stack are listed in Nested.
 type ResourceInfo struct {
 	ID           string
 	Name         string
@@ -118,6 +128,7 @@ type ResourceInfo struct {
 	StatusReason string
 	Type         string
 	UpdatedTime  time.Time
+	Nested       ResourceInfos
 }
 
 func newResourceInfo(s *cf.StackResource) ResourceInfo {
@@ -184,6 +195,7 @@ type ResourceChange struct {
 	ResourceID   string
 	Replacement  bool
 	Details      ResourceChangeDetails
+	ChangeSetID  string // Changeset of a nested stack
 }
 
 func newResourceChange(rc *cf.ResourceChange) ResourceChange {
@@ -192,6 +204,7 @@ func newResourceChange(rc *cf.ResourceChange) ResourceChange {
 		Name:         *rc.LogicalResourceId,
 		ResourceType: *rc.ResourceType,
 		Details:      newResourceChangeDetails(rc.Details),
+		ChangeSetID:  deref(rc.ChangeSetId),
 	}
 
 	if rc.PhysicalResourceId != nil {
@@ -216,7 +229,8 @@ func newResourceChanges(changes []*cf.Change) ResourceChanges {
 	return rc
 }
 
-// ChangeSetInfo represents a change set to be applied to a stack
+// ChangeSetInfo represents a change set to be applied to a stack. The
+// changesets of nested stacks it changes are held in Nested.
 type ChangeSetInfo struct {
 	ID              string
 	Name            string
@@ -228,6 +242,8 @@ type ChangeSetInfo struct {
 	StackName       string
 	Changes         ResourceChanges
 	Params          StackParamInfos
+	Tags            StackTagInfos
+	Nested          []*ChangeSetInfo
 }
 
 func newChangeSetInfo(cso *cf.DescribeChangeSetOutput) *ChangeSetInfo {
@@ -241,6 +257,7 @@ func newChangeSetInfo(cso *cf.DescribeChangeSetOutput) *ChangeSetInfo {
 		StatusReason:    deref(cso.StatusReason),
 		Changes:         newResourceChanges(cso.Changes),
 		Params:          newStackParamInfos(cso.Parameters),
+		Tags:            newStackTagInfos(cso.Tags),
 	}
 
 	if cso.CreationTime != nil {
@@ -283,12 +300,20 @@ func (cs *ChangeSetInfo) String() string {
 		buffer.WriteString(fmt.Sprintf("  %s\n", bold("Resources")))
 	}
 
+	cs.writeChanges(&buffer, "    ")
+
+	return buffer.String()
+}
+
+// writeChanges writes each resource change at the given indent. The changes
+// of a nested stack are written beneath its resource.
+func (cs *ChangeSetInfo) writeChanges(buffer *bytes.Buffer, indent string) {
 	for _, r := range cs.Changes {
 
 		if r.ResourceID == "" {
-			buffer.WriteString(fmt.Sprintf("    %s: %s\n", underline(bold(r.ResourceType)), cyan(r.Name)))
+			buffer.WriteString(fmt.Sprintf("%s%s: %s\n", indent, underline(bold(r.ResourceType)), cyan(r.Name)))
 		} else {
-			buffer.WriteString(fmt.Sprintf("    %s: %s (%s)\n", underline(bold(r.ResourceType)), cyan(r.Name), cyan(r.ResourceID)))
+			buffer.WriteString(fmt.Sprintf("%s%s: %s (%s)\n", indent, underline(bold(r.ResourceType)), cyan(r.Name), cyan(r.ResourceID)))
 		}
 
 		var action string
@@ -301,32 +326,52 @@ func (cs *ChangeSetInfo) String() string {
 			action = red(r.Action)
 		}
 
-		buffer.WriteString(fmt.Sprintf("      %s: %s\n", bold("Action"), action))
+		buffer.WriteString(fmt.Sprintf("%s  %s: %s\n", indent, bold("Action"), action))
 
 		if r.Replacement {
-			buffer.WriteString(fmt.Sprintf("      %s: %s\n", bold("Replacement"), red(r.Replacement)))
+			buffer.WriteString(fmt.Sprintf("%s  %s: %s\n", indent, bold("Replacement"), red(r.Replacement)))
 		} else {
-			buffer.WriteString(fmt.Sprintf("      %s: %s\n", bold("Replacement"), cyan(r.Replacement)))
+			buffer.WriteString(fmt.Sprintf("%s  %s: %s\n", indent, bold("Replacement"), cyan(r.Replacement)))
 		}
 
-		// if len(r.Details) == 0 {
-		// 	continue
-		// }
+		nested := cs.nested(r.ChangeSetID)
+		if nested == nil {
+			continue
+		}
 
-		// buffer.WriteString(fmt.Sprintf("      %s\n", bold("Details")))
+		if nested.StatusReason != "" && nested.Status == cf.ChangeSetStatusFailed {
+			buffer.WriteString(fmt.Sprintf("%s  %s: %s\n", indent, bold("Reason"), red(nested.StatusReason)))
+		}
 
-		// for _, d := range r.Details {
-		// 	buffer.WriteString(fmt.Sprintf("        %s\n", bold(d.ChangeSource)))
-		// 	if d.CausingEntity != "" {
-		// 		buffer.WriteString(fmt.Sprintf("          %s: %s\n", bold("Entity"), cyan(d.CausingEntity)))
-		// 	}
-		// 	buffer.WriteString(fmt.Sprintf("          %s: %s\n", bold("Evaluation"), cyan(d.Evaluation)))
-		// 	buffer.WriteString(fmt.Sprintf("          %s: %s\n", bold("Attribute"), cyan(d.Attribute)))
-		// 	buffer.WriteString(fmt.Sprintf("          %s: %s\n", bold("RequiresRecreation"), cyan(d.RequiresRecreation)))
-		// }
+		if len(nested.Changes) > 0 {
+			buffer.WriteString(fmt.Sprintf("%s  %s\n", indent, bold("Resources")))
+			nested.writeChanges(buffer, indent+"    ")
+		}
 	}
+}
 
-	return buffer.String()
+// nested returns the nested changeset with the given ID
+func (cs *ChangeSetInfo) nested(id string) *ChangeSetInfo {
+	if id == "" {
+		return nil
+	}
+
+	for _, n := range cs.Nested {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// AllChanges returns the changeset's changes along with those of every nested
+// changeset
+func (cs *ChangeSetInfo) AllChanges() ResourceChanges {
+	changes := append(ResourceChanges{}, cs.Changes...)
+	for _, n := range cs.Nested {
+		changes = append(changes, n.AllChanges()...)
+	}
+	return changes
 }
 
 // CanCommit returns whether a changeset can be committed
@@ -334,6 +379,16 @@ func (cs *ChangeSetInfo) CanCommit() bool {
 	return cs.ExecutionStatus == cf.ExecutionStatusAvailable
 }
 
+// IsEmpty returns whether a changeset failed only because it contained no changes
+func (cs *ChangeSetInfo) IsEmpty() bool {
+	if cs.Status != cf.ChangeSetStatusFailed || len(cs.Changes) > 0 {
+		return false
+	}
+
+	return strings.Contains(cs.StatusReason, "didn't contain changes") ||
+		strings.Contains(cs.StatusReason, "No updates are to be performed")
+}
+
 // PendingChangeSets is a list of PendingChangeSet
 type PendingChangeSets []PendingChangeSet
 
@@ -392,7 +447,7 @@ func (spi StackParamInfos) String() string {
 	data := make([][]string, len(spi))
 
 	for i, p := range spi {
-		data[i] = []string{"", bold(p.Key), cyan(p.Value)}
+		data[i] = []string{"", bold(p.Key), cyan(p.DisplayValue())}
 	}
 
 	table := tablewriter.NewWriter(&buffer)
@@ -404,10 +459,30 @@ func (spi StackParamInfos) String() string {
 	return buffer.String()
 }
 
-// StackParamInfo represents a Stack's parameters
+// StackParamInfo represents a Stack's parameters. The values of sensitive
+// parameters are masked when displayed.
 type StackParamInfo struct {
-	Key   string
-	Value string
+	Key       string
+	Value     string
+	Sensitive bool
+}
+
+// SensitiveValueMask is displayed in place of the value of sensitive parameters
+const SensitiveValueMask = "<sensitive>"
+
+// DisplayValue returns the parameter's value, masked if it is sensitive
+func (spi StackParamInfo) DisplayValue() string {
+	if spi.Sensitive {
+		return SensitiveValueMask
+	}
+	return spi.Value
+}
+
+// MarkSensitive flags each parameter for which sensitive returns true
+func (spi StackParamInfos) MarkSensitive(sensitive func(key string) bool) {
+	for i := range spi {
+		spi[i].Sensitive = sensitive(spi[i].Key)
+	}
 }
 
 func newStackParamInfo(param *cf.Parameter) StackParamInfo {
@@ -417,6 +492,48 @@ func newStackParamInfo(param *cf.Parameter) StackParamInfo {
 	}
 }
 
+// StackTagInfos is a list of StackTagInfo
+type StackTagInfos []StackTagInfo
+
+func newStackTagInfos(tags []*cf.Tag) StackTagInfos {
+	sti := make(StackTagInfos, len(tags))
+	for i, t := range tags {
+		sti[i] = newStackTagInfo(t)
+	}
+	return sti
+}
+
+func (sti StackTagInfos) String() string {
+	var buffer bytes.Buffer
+
+	data := make([][]string, len(sti))
+
+	for i, t := range sti {
+		data[i] = []string{"", bold(t.Key), cyan(t.Value)}
+	}
+
+	table := tablewriter.NewWriter(&buffer)
+	table.SetColumnSeparator("")
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	return buffer.String()
+}
+
+// StackTagInfo represents a tag applied to a Stack
+type StackTagInfo struct {
+	Key   string
+	Value string
+}
+
+func newStackTagInfo(tag *cf.Tag) StackTagInfo {
+	return StackTagInfo{
+		Key:   deref(tag.Key),
+		Value: deref(tag.Value),
+	}
+}
+
 // StackOutputInfos is a list of StackParamInfo
 type StackOutputInfos []StackOutputInfo
 
@@ -461,13 +578,15 @@ func newStackOutputInfo(output *cf.Output) StackOutputInfo {
 
 // StackInfo represents the state of a stack in Cloudformation
 type StackInfo struct {
-	ID              string
-	Name            string
-	Status          string
-	CreationTime    time.Time
-	LastUpdatedTime time.Time
-	Params          StackParamInfos
-	Outputs         StackOutputInfos
+	ID                    string
+	Name                  string
+	Status                string
+	CreationTime          time.Time
+	LastUpdatedTime       time.Time
+	TerminationProtection bool
+	Params                StackParamInfos
+	Outputs               StackOutputInfos
+	Tags                  StackTagInfos
 }
 
 func newStackInfo(stack *cf.Stack) *StackInfo {
@@ -479,6 +598,11 @@ func newStackInfo(stack *cf.Stack) *StackInfo {
 		LastUpdatedTime: *stack.CreationTime,
 		Params:          newStackParamInfos(stack.Parameters),
 		Outputs:         newStackOutputInfos(stack.Outputs),
+		Tags:            newStackTagInfos(stack.Tags),
+	}
+
+	if stack.EnableTerminationProtection != nil {
+		si.TerminationProtection = *stack.EnableTerminationProtection
 	}
 
 	if stack.LastUpdatedTime != nil {
@@ -497,6 +621,7 @@ func (si *StackInfo) String() string {
 		{bold("Status"), cyan(si.Status)},
 		{bold("CreationTime"), cyan(si.CreationTime)},
 		{bold("LastUpdatedTime"), cyan(si.LastUpdatedTime)},
+		{bold("TerminationProtection"), cyan(si.TerminationProtection)},
 	}
 
 	table := tablewriter.NewWriter(&buffer)
@@ -513,9 +638,25 @@ func (si *StackInfo) String() string {
 	buffer.WriteString(fmt.Sprintf("  %s\n", bold("Outputs")))
 	buffer.WriteString(si.Outputs.String())
 
+	if len(si.Tags) > 0 {
+		buffer.WriteString(fmt.Sprintf("  %s\n", bold("Tags")))
+		buffer.WriteString(si.Tags.String())
+	}
+
 	return buffer.String()
 }
 
+// Succeeded indicates whether the last create or update of a stack completed
+// successfully
+func (si *StackInfo) Succeeded() bool {
+	switch si.Status {
+	case cf.StackStatusCreateComplete, cf.StackStatusUpdateComplete:
+		return true
+	default:
+		return false
+	}
+}
+
 // CanUpdate indicates whether a stack can be updated
 func (si *StackInfo) CanUpdate() bool {
 	switch si.Status {
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// Uploader stores artifacts which cloudformation reads from S3, returning the
+// URL of each uploaded artifact
+type Uploader interface {
+	Upload(bucket string, key string, body []byte) (string, error)
+}
+
+// S3Client provides access to the S3 apis needed to upload artifacts
+type S3Client interface {
+	HeadObject(*s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+	PutObject(*s3.PutObjectInput) (*s3.PutObjectOutput, error)
+}
+
+// NewS3Client creates a new S3Client given a region and profile
+func NewS3Client(region string, profile string) (S3Client, error) {
+	s, err := newSession(region, profile)
+	if err != nil {
+		return nil, err
+	}
+	return s3.New(s), nil
+}
+
+// S3Uploader uploads artifacts to buckets within a single region. Keys are
+// expected to address their content, so existing objects aren't uploaded
+// again.
+type S3Uploader struct {
+	s3     S3Client
+	region string
+}
+
+// NewS3Uploader returns an Uploader for buckets within a region
+func NewS3Uploader(s3 S3Client, region string) *S3Uploader {
+	return &S3Uploader{s3: s3, region: region}
+}
+
+func (u *S3Uploader) Upload(bucket string, key string, body []byte) (string, error) {
+	url := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, u.region, key)
+
+	_, err := u.s3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		return url, nil
+	}
+
+	// Without s3:ListBucket, a missing object is reported as forbidden rather
+	// than not found, so the object is uploaded in either case
+	aerr, ok := err.(awserr.RequestFailure)
+	if !ok || (aerr.StatusCode() != 404 && aerr.StatusCode() != 403) {
+		return "", errors.Wrapf(err, "unable to check for s3://%s/%s", bucket, key)
+	}
+
+	_, err = u.s3.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to upload s3://%s/%s", bucket, key)
+	}
+
+	return url, nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockS3 struct {
+	mock.Mock
+}
+
+func (c *mockS3) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	r := c.Called(input)
+	o, _ := r.Get(0).(*s3.HeadObjectOutput)
+	return o, r.Error(1)
+}
+
+func (c *mockS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	r := c.Called(input)
+	o, _ := r.Get(0).(*s3.PutObjectOutput)
+	return o, r.Error(1)
+}
+
+func TestS3UploaderUpload(t *testing.T) {
+	var (
+		url      = "https://artifacts.s3.us-west-2.amazonaws.com/templates/abc.template"
+		head     = &s3.HeadObjectInput{Bucket: aws.String("artifacts"), Key: aws.String("templates/abc.template")}
+		put      = &s3.PutObjectInput{Bucket: aws.String("artifacts"), Key: aws.String("templates/abc.template"), Body: bytes.NewReader([]byte("body"))}
+		notFound = awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, "reqid")
+		denied   = awserr.NewRequestFailure(awserr.New("Forbidden", "Forbidden", nil), 403, "reqid")
+		failed   = awserr.NewRequestFailure(awserr.New("InternalError", "Internal Error", nil), 500, "reqid")
+	)
+
+	scenarios := []struct {
+		headErr error
+		upload  bool
+		putErr  error
+
+		expected string
+		errored  bool
+	}{
+		{nil, false, nil, url, false},
+		{notFound, true, nil, url, false},
+		{notFound, true, errors.New("boom"), "", true},
+		{denied, true, nil, url, false},
+		{failed, false, nil, "", true},
+	}
+
+	for _, s := range scenarios {
+		client := &mockS3{}
+		client.On("HeadObject", head).Return(&s3.HeadObjectOutput{}, s.headErr)
+		client.On("PutObject", put).Return(&s3.PutObjectOutput{}, s.putErr)
+
+		u := NewS3Uploader(client, "us-west-2")
+
+		result, err := u.Upload("artifacts", "templates/abc.template", []byte("body"))
+
+		assert.Equal(t, s.expected, result)
+		if s.errored {
+			assert.Error(t, err)
+		} else {
+			assert.Nil(t, err)
+		}
+
+		if s.upload {
+			client.AssertCalled(t, "PutObject", put)
+		} else {
+			client.AssertNotCalled(t, "PutObject", put)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package client
+
+import "github.com/aws/aws-sdk-go/service/secretsmanager"
+
+// SecretsManagerClient provides access to the secrets manager apis used by the
+// Secret resolver
+type SecretsManagerClient interface {
+	GetSecretValue(*secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// NewSecretsManagerClient creates a new SecretsManagerClient given a region and profile
+func NewSecretsManagerClient(region string, profile string) (SecretsManagerClient, error) {
+	s, err := newSession(region, profile)
+	if err != nil {
+		return nil, err
+	}
+	return secretsmanager.New(s), nil
+}
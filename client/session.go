@@ -0,0 +1,20 @@
+package client
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// newSession creates a session for a region, using a named profile from the
+// shared aws config. The default credentials are used when profile is empty.
+func newSession(region string, profile string) (*session.Session, error) {
+	if profile == "" {
+		return session.NewSession(&aws.Config{Region: aws.String(region)})
+	}
+
+	return session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Region: aws.String(region)},
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+}
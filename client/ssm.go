@@ -0,0 +1,17 @@
+package client
+
+import "github.com/aws/aws-sdk-go/service/ssm"
+
+// SSMClient provides access to the parameter store apis used by the SSM resolver
+type SSMClient interface {
+	GetParameter(*ssm.GetParameterInput) (*ssm.GetParameterOutput, error)
+}
+
+// NewSSMClient creates a new SSMClient given a region and profile
+func NewSSMClient(region string, profile string) (SSMClient, error) {
+	s, err := newSession(region, profile)
+	if err != nil {
+		return nil, err
+	}
+	return ssm.New(s), nil
+}
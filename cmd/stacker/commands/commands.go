@@ -3,13 +3,17 @@ package commands
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	cf "github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/fatih/color"
 	"github.com/jawher/mow.cli"
 	"github.com/olekukonko/tablewriter"
@@ -28,13 +32,53 @@ var (
 	red       = color.New(color.FgRed).SprintFunc()
 )
 
-func newStackerClient(region string) *client.Client {
-	return client.New(client.NewCloudformationClient(region))
+// allowAllStackPolicy is equivalent to a stack having no policy. A stack's
+// policy cannot be removed, only replaced.
+const allowAllStackPolicy = `{
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "Update:*",
+      "Principal": "*",
+      "Resource": "*"
+    }
+  ]
+}`
+
+// newStackerClient creates a client for the region and account of a stack,
+// exiting when it can't be created
+func newStackerClient(stack stacker.Stack) *client.Client {
+	c, err := stackerClient(stack.Region(), stack.Profile())
+	if err != nil {
+		exitWithError(err)
+	}
+	return c
+}
+
+// stackerClient creates a client for a region, using a named profile from the
+// shared aws config unless profile is empty
+func stackerClient(region string, profile string) (*client.Client, error) {
+	cf, err := client.NewCloudformationClientWithProfile(region, profile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create cloudformation client")
+	}
+
+	s3, err := client.NewS3Client(region, profile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create s3 client")
+	}
+
+	return client.NewWithUploader(cf, client.NewS3Uploader(s3, region)), nil
 }
 
 type Backend interface {
 	FetchAll() ([]stacker.Stack, error)
+	FetchEveryEnv() ([]stacker.Stack, error)
 	Fetch(name string) ([]stacker.Stack, error)
+	FetchEnv(env string) ([]stacker.Stack, error)
+	FetchPolicy(name string) (string, error)
+	Check() ([]string, error)
+	Render(stack stacker.Stack, offline bool) (*stacker.RenderedStack, error)
 }
 
 func List(b Backend) func(cmd *cli.Cmd) {
@@ -65,19 +109,22 @@ func Update(b Backend) func(cmd *cli.Cmd) {
 		var (
 			stack            stacker.Stack
 			stackerCli       *client.Client
-			stackName        = cmd.StringArg("STACK", "", "Stack name")
+			temporaryPolicy  string
+			stackName        = cmd.StringArg("STACK", "", "Stack name, optionally prefixed with an environment path")
 			allowDestructive = cmd.Bool(cli.BoolOpt{
 				Name:  "y allow-destructive",
 				Value: false,
 				Desc:  "Allow destructive changes",
 			})
+			temporaryPolicyName = cmd.StringOpt("temporary-policy", "", "Name of a policy in policies/ to protect the stack with while the changes are applied")
 		)
 
-		cmd.Spec = "STACK [-y] [--allow-destructive]"
+		cmd.Spec = "STACK [-y] [--allow-destructive] [--temporary-policy=<policy>]"
 
 		cmd.Before = func() {
 			stack = fetchStack(b, *stackName)
-			stackerCli = newStackerClient(stack.Region())
+			stackerCli = newStackerClient(stack)
+			temporaryPolicy = fetchPolicy(b, *temporaryPolicyName)
 		}
 
 		cmd.Action = func() {
@@ -86,13 +133,19 @@ func Update(b Backend) func(cmd *cli.Cmd) {
 				exitWithError(err)
 			}
 
-			review(stackerCli, cs)
+			if err := review(stackerCli, stack, cs); err != nil {
+				exitWithError(err)
+			}
 
 			if !confirmChanges(cs, *allowDestructive) {
 				os.Exit(1)
 			}
 
-			if err := apply(stackerCli, cs); err != nil {
+			if err := applyWithPolicy(stackerCli, stack, cs, temporaryPolicy, os.Stdout, ""); err != nil {
+				exitWithError(err)
+			}
+
+			if err := updateTerminationProtection(stackerCli, stack, os.Stdout); err != nil {
 				exitWithError(err)
 			}
 
@@ -106,14 +159,14 @@ func Plan(b Backend) func(cmd *cli.Cmd) {
 		var (
 			stack      stacker.Stack
 			stackerCli *client.Client
-			stackName  = cmd.StringArg("STACK", "", "Stack name")
+			stackName  = cmd.StringArg("STACK", "", "Stack name, optionally prefixed with an environment path")
 		)
 
 		cmd.Spec = "STACK"
 
 		cmd.Before = func() {
 			stack = fetchStack(b, *stackName)
-			stackerCli = newStackerClient(stack.Region())
+			stackerCli = newStackerClient(stack)
 		}
 
 		cmd.Action = func() {
@@ -129,13 +182,13 @@ func Plan(b Backend) func(cmd *cli.Cmd) {
 			fmt.Printf(
 				"  %s: `%s`\n",
 				bold("Review these changes with"),
-				cyan(fmt.Sprintf("stacker review %s %s", cs.StackName, cs.Name)),
+				cyan(fmt.Sprintf("stacker review %s %s", *stackName, cs.Name)),
 			)
 
 			fmt.Printf(
 				"  %s: `%s`\n\n",
 				bold("Apply these changes with"),
-				cyan(fmt.Sprintf("stacker apply %s %s", cs.StackName, cs.Name)),
+				cyan(fmt.Sprintf("stacker apply %s %s", *stackName, cs.Name)),
 			)
 		}
 	}
@@ -146,7 +199,7 @@ func Review(b Backend) func(cmd *cli.Cmd) {
 		var (
 			stack      stacker.Stack
 			stackerCli *client.Client
-			stackName  = cmd.StringArg("STACK", "", "Stack name")
+			stackName  = cmd.StringArg("STACK", "", "Stack name, optionally prefixed with an environment path")
 			changeSet  = cmd.StringArg("CHANGESET", "", "Changeset name")
 		)
 
@@ -156,17 +209,19 @@ func Review(b Backend) func(cmd *cli.Cmd) {
 
 		cmd.Before = func() {
 			stack = fetchStack(b, *stackName)
-			stackerCli = newStackerClient(stack.Region())
-			ensureStackExists(stackerCli, *stackName)
+			stackerCli = newStackerClient(stack)
+			ensureStackExists(stackerCli, stack.Name())
 		}
 
 		cmd.Action = func() {
-			cs, err := fetchChangeSet(stackerCli, *stackName, *changeSet)
+			cs, err := fetchChangeSet(stackerCli, stack.Name(), *changeSet)
 			if err != nil {
 				exitWithError(err)
 			}
 
-			review(stackerCli, cs)
+			if err := review(stackerCli, stack, cs); err != nil {
+				exitWithError(err)
+			}
 
 			if !cs.CanCommit() {
 				return
@@ -175,7 +230,7 @@ func Review(b Backend) func(cmd *cli.Cmd) {
 			fmt.Printf(
 				"  %s: `%s`\n\n",
 				bold("Apply these changes with"),
-				cyan(fmt.Sprintf("stacker apply %s %s", cs.StackName, cs.Name)),
+				cyan(fmt.Sprintf("stacker apply %s %s", *stackName, cs.Name)),
 			)
 		}
 	}
@@ -186,38 +241,47 @@ func Apply(b Backend) func(cmd *cli.Cmd) {
 		var (
 			stack            stacker.Stack
 			stackerCli       *client.Client
-			stackName        = cmd.StringArg("STACK", "", "Stack name")
+			temporaryPolicy  string
+			stackName        = cmd.StringArg("STACK", "", "Stack name, optionally prefixed with an environment path")
 			changeSet        = cmd.StringArg("CHANGESET", "", "Changeset name")
 			allowDestructive = cmd.Bool(cli.BoolOpt{
 				Name:  "y allow-destructive",
 				Value: false,
 				Desc:  "Allow destructive changes",
 			})
+			temporaryPolicyName = cmd.StringOpt("temporary-policy", "", "Name of a policy in policies/ to protect the stack with while the changes are applied")
 		)
 
-		cmd.Spec = "STACK [CHANGESET] [-y] [--allow-destructive]"
+		cmd.Spec = "STACK [CHANGESET] [-y] [--allow-destructive] [--temporary-policy=<policy>]"
 
 		// @TODO Allow stack to not exist locally for this
 
 		cmd.Before = func() {
 			stack = fetchStack(b, *stackName)
-			stackerCli = newStackerClient(stack.Region())
-			ensureStackExists(stackerCli, *stackName)
+			stackerCli = newStackerClient(stack)
+			ensureStackExists(stackerCli, stack.Name())
+			temporaryPolicy = fetchPolicy(b, *temporaryPolicyName)
 		}
 
 		cmd.Action = func() {
-			cs, err := fetchChangeSet(stackerCli, *stackName, *changeSet)
+			cs, err := fetchChangeSet(stackerCli, stack.Name(), *changeSet)
 			if err != nil {
 				exitWithError(err)
 			}
 
-			review(stackerCli, cs)
+			if err := review(stackerCli, stack, cs); err != nil {
+				exitWithError(err)
+			}
 
 			if !confirmChanges(cs, *allowDestructive) {
 				os.Exit(1)
 			}
 
-			if err := apply(stackerCli, cs); err != nil {
+			if err := applyWithPolicy(stackerCli, stack, cs, temporaryPolicy, os.Stdout, ""); err != nil {
+				exitWithError(err)
+			}
+
+			if err := updateTerminationProtection(stackerCli, stack, os.Stdout); err != nil {
 				exitWithError(err)
 			}
 
@@ -226,7 +290,7 @@ func Apply(b Backend) func(cmd *cli.Cmd) {
 			fmt.Printf(
 				"\n  %s: `%s`\n\n",
 				bold("View stack status with"),
-				cyan(fmt.Sprintf("stacker show %s", cs.StackName)),
+				cyan(fmt.Sprintf("stacker show %s", *stackName)),
 			)
 		}
 	}
@@ -237,7 +301,7 @@ func Delete(b Backend) func(cmd *cli.Cmd) {
 		var (
 			stack      stacker.Stack
 			stackerCli *client.Client
-			stackName  = cmd.StringArg("STACK", "", "Stack name")
+			stackName  = cmd.StringArg("STACK", "", "Stack name, optionally prefixed with an environment path")
 		)
 
 		// @TODO Allow stack to not exist locally for this
@@ -246,23 +310,23 @@ func Delete(b Backend) func(cmd *cli.Cmd) {
 
 		cmd.Before = func() {
 			stack = fetchStack(b, *stackName)
-			stackerCli = newStackerClient(stack.Region())
-			ensureStackExists(stackerCli, *stackName)
+			stackerCli = newStackerClient(stack)
+			ensureStackExists(stackerCli, stack.Name())
 		}
 
 		cmd.Action = func() {
-			fmt.Printf("%s: %s\n\n", bold("Deleting stack"), cyan(*stackName))
+			fmt.Printf("%s: %s\n\n", bold("Deleting stack"), cyan(stack.Name()))
 			fmt.Printf("  %s\n\n",
 				underline("This is a destructive action and will delete your stack and all of its associated resources!"),
 			)
 
-			if confirm(bold("  Enter stack name to continue: ")) != *stackName {
+			if confirm(bold("  Enter stack name to continue: ")) != stack.Name() {
 				exitWithError(errors.New("deletion must be confirmed with stack name"))
 			}
 
 			fmt.Println()
 
-			if err := deleteStack(stackerCli, *stackName); err != nil {
+			if err := deleteStack(stackerCli, stack.Name(), os.Stdout, ""); err != nil {
 				exitWithError(err)
 			}
 		}
@@ -274,19 +338,19 @@ func Show(b Backend) func(cmd *cli.Cmd) {
 		var (
 			stack      stacker.Stack
 			stackerCli *client.Client
-			stackName  = cmd.StringArg("STACK", "", "Stack name")
+			stackName  = cmd.StringArg("STACK", "", "Stack name, optionally prefixed with an environment path")
 		)
 
 		cmd.Spec = "STACK"
 
 		cmd.Before = func() {
 			stack = fetchStack(b, *stackName)
-			stackerCli = newStackerClient(stack.Region())
-			ensureStackExists(stackerCli, *stackName)
+			stackerCli = newStackerClient(stack)
+			ensureStackExists(stackerCli, stack.Name())
 		}
 
 		cmd.Action = func() {
-			if err := show(stackerCli, *stackName); err != nil {
+			if err := show(stackerCli, stack); err != nil {
 				exitWithError(err)
 			}
 		}
@@ -303,8 +367,12 @@ func fetchLocal(b Backend) ([]stacker.Stack, error) {
 	return stacks, nil
 }
 
-func fetchRemote(region string) ([]*client.StackInfo, error) {
-	cli := newStackerClient(region)
+func fetchRemote(region string, profile string) ([]*client.StackInfo, error) {
+	cli, err := stackerClient(region, profile)
+	if err != nil {
+		return nil, err
+	}
+
 	stacks, err := cli.ListStacks()
 	if err != nil {
 		return nil, err
@@ -315,23 +383,25 @@ func fetchRemote(region string) ([]*client.StackInfo, error) {
 }
 
 func compareWithRemote(local []stacker.Stack) ([]string, error) {
+	// Stacks are listed once for each region and profile
 	remoteByRegion := make(map[string][]*client.StackInfo)
 	for _, s := range local {
-		if _, ok := remoteByRegion[s.Region()]; ok {
+		k := s.Region() + "/" + s.Profile()
+		if _, ok := remoteByRegion[k]; ok {
 			continue
 		}
 
-		remote, err := fetchRemote(s.Region())
+		remote, err := fetchRemote(s.Region(), s.Profile())
 		if err != nil {
 			return nil, err
 		}
-		remoteByRegion[s.Region()] = remote
+		remoteByRegion[k] = remote
 	}
 
 	statuses := make([]string, len(local))
 	for i, s := range local {
 		statuses[i] = "not created"
-		for _, existing := range remoteByRegion[s.Region()] {
+		for _, existing := range remoteByRegion[s.Region()+"/"+s.Profile()] {
 			if s.Name() == existing.Name {
 				statuses[i] = ""
 				break
@@ -379,7 +449,7 @@ func listLocal(b Backend) error {
 }
 
 func listRemote(b Backend, region string) error {
-	remote, err := fetchRemote(region)
+	remote, err := fetchRemote(region, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to fetch remote stacks")
 	}
@@ -418,17 +488,35 @@ func fetchStack(b Backend, name string) stacker.Stack {
 		exitWithError(err)
 	}
 
-	if len(s) > 1 {
-		exitWithError(fmt.Errorf("multiple stacks found for `%s`", name))
+	names := make([]string, len(s))
+	for i, st := range s {
+		names[i] = st.Name()
 	}
 
-	if len(s) == 0 {
-		exitWithError(fmt.Errorf("no stack found for `%s`", name))
+	if err := selectionError(name, names); err != nil {
+		exitWithError(err)
 	}
 
 	return s[0]
 }
 
+// selectionError returns an error unless a stack selector matched exactly one
+// stack, given the names of the stacks it matched
+func selectionError(name string, matches []string) error {
+	if len(matches) > 1 {
+		return fmt.Errorf(
+			"multiple stacks found for `%s`, select one with an environment path such as `production/%s` or --env",
+			name, matches[0],
+		)
+	}
+
+	if len(matches) == 0 {
+		return fmt.Errorf("no stack found for `%s`", name)
+	}
+
+	return nil
+}
+
 // Plan creates a new changeset given a client and a stack
 func plan(stacker *client.Client, stack stacker.Stack) (*client.ChangeSetInfo, error) {
 	var (
@@ -437,6 +525,10 @@ func plan(stacker *client.Client, stack stacker.Stack) (*client.ChangeSetInfo, e
 		err error
 	)
 
+	if err = validate(stack); err != nil {
+		return nil, err
+	}
+
 	if si, err = stacker.Get(stack.Name()); err != nil {
 		return nil, errors.Wrap(err, "failed to fetch stack information")
 	}
@@ -470,60 +562,182 @@ func plan(stacker *client.Client, stack stacker.Stack) (*client.ChangeSetInfo, e
 	return stacker.GetChangeSet(cs.StackName, cs.Name)
 }
 
-// Review displays information about a changeset
-func review(stacker *client.Client, changeSet *client.ChangeSetInfo) {
+// Review displays information about a changeset, along with any change to the
+// stack's policy
+func review(stacker *client.Client, stack stacker.Stack, changeSet *client.ChangeSetInfo) error {
 	fmt.Println(changeSet)
 
 	stackInfo, err := stacker.Get(changeSet.StackName)
 	if err != nil {
-		exitWithError(fmt.Errorf("error fetching information for stack %s", changeSet.StackName))
+		return fmt.Errorf("error fetching information for stack %s", changeSet.StackName)
 	}
 
-	reviewStackParams(changeSet.Params, stackInfo.Params)
+	reviewStackParams(changeSet.Params, stackInfo.Params, sensitiveParams(stack), previousParams(stack))
+	reviewStackTags(changeSet.Tags, stackInfo.Tags)
 
 	stackTemplate, err := stacker.GetTemplate(changeSet.StackName)
 	if err != nil {
-		exitWithError(fmt.Errorf("error fetching template for stack %s", changeSet.StackName))
+		return fmt.Errorf("error fetching template for stack %s", changeSet.StackName)
 	}
 
 	changeSetTemplate, err := stacker.GetChangeSetTemplate(changeSet.StackName, changeSet.Name)
 	if err != nil {
-		exitWithError(fmt.Errorf("error fetching template for changeset %s", changeSet.Name))
+		return fmt.Errorf("error fetching template for changeset %s", changeSet.Name)
 	}
 
-	reviewStackTemplate(stackTemplate, changeSetTemplate)
+	if err := reviewStackTemplate(stackTemplate, changeSetTemplate); err != nil {
+		return err
+	}
+
+	stackPolicy, err := stacker.GetStackPolicy(changeSet.StackName)
+	if err != nil {
+		return fmt.Errorf("error fetching policy for stack %s", changeSet.StackName)
+	}
+
+	// Stacks without a policy, either deployed or local, have nothing to review
+	if stackPolicy == "" && stack.StackPolicy() == "" {
+		return nil
+	}
+
+	return reviewStackPolicy(policyOrAllowAll(stackPolicy), policyOrAllowAll(stack.StackPolicy()))
 }
 
-// Apply executes a changeset against a stack
-func apply(stacker *client.Client, changeSet *client.ChangeSetInfo) error {
+// Apply executes a changeset against a stack, writing its progress to out.
+// Stack events are printed with the provided prefix while waiting for the
+// changeset to apply.
+func apply(stacker *client.Client, changeSet *client.ChangeSetInfo, out io.Writer, prefix string) error {
 	if !changeSet.CanCommit() {
 		return errors.Errorf("change set %s cannot be applied. status=%s", changeSet.Name, changeSet.Status)
 	}
 
-	fmt.Printf("%s %s %s %s\n", bold("Applying changeset"), cyan(changeSet.Name), bold("to"), cyan(changeSet.StackName))
+	fmt.Fprintf(out, "%s %s %s %s\n", bold("Applying changeset"), cyan(changeSet.Name), bold("to"), cyan(changeSet.StackName))
 
 	if err := stacker.Commit(changeSet.StackName, changeSet.Name); err != nil {
 		return errors.Wrapf(err, "error committing changeset %s", changeSet.Name)
 	}
 
-	fmt.Printf("%s... %s\n\n", bold("Waiting for changeset to apply"), "use ^C to exit safely")
+	fmt.Fprintf(out, "%s... %s\n\n", bold("Waiting for changeset to apply"), "use ^C to exit safely")
+
+	return stacker.NotifyUntilComplete(changeSet.StackName, showStackEvents(stacker, out, prefix))
+}
+
+// applyWithPolicy applies a changeset while the stack is protected by a
+// temporary policy when one is provided, or otherwise by its own policy. The
+// stack's own policy is restored once the changeset has been applied, whether
+// or not it succeeded.
+func applyWithPolicy(stacker *client.Client, stack stacker.Stack, changeSet *client.ChangeSetInfo, temporaryPolicy string, out io.Writer, prefix string) error {
+	policy, err := prepareStackPolicy(stacker, stack, temporaryPolicy, out)
+	if err != nil {
+		return err
+	}
+
+	applyErr := apply(stacker, changeSet, out, prefix)
+
+	if policy != "" {
+		if err := setStackPolicy(stacker, stack.Name(), policy, out); err != nil && applyErr == nil {
+			return err
+		}
+	}
 
-	return stacker.NotifyUntilComplete(changeSet.StackName, showStackEvents(stacker))
+	return applyErr
+}
+
+// prepareStackPolicy sets the policy which protects a stack while a changeset
+// is applied. It returns the policy to set once the changeset has been
+// applied, or an empty string when the policy is already in place.
+func prepareStackPolicy(stacker *client.Client, stack stacker.Stack, temporaryPolicy string, out io.Writer) (string, error) {
+	si, err := stacker.Get(stack.Name())
+	if err != nil {
+		return "", errors.Wrapf(err, "error fetching stack %s", stack.Name())
+	}
+
+	// Stacks without resources have nothing to protect, so the policy is set
+	// once they've been created
+	if si == nil || si.Status == cf.StackStatusReviewInProgress {
+		return stack.StackPolicy(), nil
+	}
+
+	current, err := stacker.GetStackPolicy(stack.Name())
+	if err != nil {
+		return "", errors.Wrapf(err, "error fetching policy for stack %s", stack.Name())
+	}
+	current = policyOrAllowAll(current)
+
+	// A policy removed from the stack's config is replaced by one allowing
+	// every update
+	final := policyOrAllowAll(stack.StackPolicy())
+
+	during := final
+	if temporaryPolicy != "" {
+		during = temporaryPolicy
+	}
+
+	if !samePolicy(during, current) {
+		if err := setStackPolicy(stacker, stack.Name(), during, out); err != nil {
+			return "", err
+		}
+	}
+
+	if samePolicy(final, during) {
+		return "", nil
+	}
+	return final, nil
+}
+
+func setStackPolicy(stacker *client.Client, stackName string, policy string, out io.Writer) error {
+	fmt.Fprintf(out, "%s %s\n", bold("Setting stack policy for"), cyan(stackName))
+
+	return errors.Wrapf(stacker.SetStackPolicy(stackName, policy), "error setting policy for stack %s", stackName)
+}
+
+// policyOrAllowAll returns a policy, or the allow all policy in place of an
+// empty one
+func policyOrAllowAll(policy string) string {
+	if policy == "" {
+		return allowAllStackPolicy
+	}
+	return policy
+}
+
+// samePolicy compares two policy documents, ignoring their formatting
+func samePolicy(a, b string) bool {
+	var docA, docB interface{}
+	if json.Unmarshal([]byte(a), &docA) != nil || json.Unmarshal([]byte(b), &docB) != nil {
+		return a == b
+	}
+	return reflect.DeepEqual(docA, docB)
+}
+
+// fetchPolicy fetches the body of a named policy, exiting on failure. An
+// empty name returns an empty policy.
+func fetchPolicy(b Backend, name string) string {
+	if name == "" {
+		return ""
+	}
+
+	policy, err := b.FetchPolicy(name)
+	if err != nil {
+		exitWithError(errors.Wrap(err, "failed to fetch policy"))
+	}
+	return policy
 }
 
 // Show prints information about a stack
-func show(stacker *client.Client, stackName string) error {
+func show(stacker *client.Client, stack stacker.Stack) error {
 	var (
 		stackInfo    *client.StackInfo
 		resourceInfo client.ResourceInfos
 		events       client.StackEvents
 		err          error
+		stackName    = stack.Name()
 	)
 
 	if stackInfo, err = stacker.Get(stackName); err != nil {
 		return errors.Wrapf(err, "error fetching stack %s", stackName)
 	}
 
+	stackInfo.Params.MarkSensitive(sensitiveParams(stack))
+
 	fmt.Println(stackInfo)
 
 	if resourceInfo, err = stacker.GetResources(stackName); err != nil {
@@ -547,17 +761,46 @@ func show(stacker *client.Client, stackName string) error {
 	return nil
 }
 
-// Delete removes a stack
-func deleteStack(stacker *client.Client, stackName string) error {
-	fmt.Printf("%s %s\n", bold("Deleting stack"), cyan(stackName))
+// Delete removes a stack, writing its progress to out. Stack events are
+// printed with the provided prefix while waiting for the deletion to complete.
+func deleteStack(stacker *client.Client, stackName string, out io.Writer, prefix string) error {
+	fmt.Fprintf(out, "%s %s\n", bold("Deleting stack"), cyan(stackName))
 
 	if err := stacker.Delete(stackName); err != nil {
 		return errors.Wrapf(err, "error deleting stack %s", stackName)
 	}
 
-	fmt.Printf("%s... %s\n", bold("Waiting for stack to complete deletion"), "use ^C to exit safely")
+	fmt.Fprintf(out, "%s... %s\n", bold("Waiting for stack to complete deletion"), "use ^C to exit safely")
+
+	return stacker.NotifyUntilComplete(stackName, showStackEvents(stacker, out, prefix))
+}
+
+// updateTerminationProtection enables or disables termination protection to
+// match the stack's configuration. Termination protection cannot be set through
+// a changeset, so it is updated once the changeset has been applied. Stacks
+// which don't configure termination protection are left untouched.
+func updateTerminationProtection(stacker *client.Client, stack stacker.Stack, out io.Writer) error {
+	enabled := stack.TerminationProtection()
+	if enabled == nil {
+		return nil
+	}
+
+	si, err := stacker.Get(stack.Name())
+	if err != nil {
+		return errors.Wrapf(err, "error fetching stack %s", stack.Name())
+	}
+
+	if si == nil || si.TerminationProtection == *enabled {
+		return nil
+	}
+
+	action := "Disabling"
+	if *enabled {
+		action = "Enabling"
+	}
+	fmt.Fprintf(out, "%s %s %s\n", bold(action), bold("termination protection for"), cyan(stack.Name()))
 
-	return stacker.NotifyUntilComplete(stackName, showStackEvents(stacker))
+	return errors.Wrapf(stacker.SetTerminationProtection(stack.Name(), *enabled), "error updating stack %s", stack.Name())
 }
 
 // fetchChangeSet fetches the ChangeSetInfo provided a stackName and changeSetName.
@@ -654,58 +897,136 @@ func confirmChanges(cs *client.ChangeSetInfo, allowDestructive bool) bool {
 	return true
 }
 
-func showStackEvents(stacker *client.Client) func(s *client.StackInfo) {
+// showStackEvents returns a callback which writes the stack events that
+// occurred since the previous call to out. Each line of output is prefixed
+// with the provided prefix, if any, so that events from concurrently updating
+// stacks remain readable.
+func showStackEvents(stacker *client.Client, out io.Writer, prefix string) func(s *client.StackInfo) {
 	cursor := time.Now()
 	return func(s *client.StackInfo) {
 		events, err := stacker.GetEvents(s.Name)
 		if err != nil {
-			fmt.Println(red("Error fetching stack events"))
+			fmt.Fprintln(out, prefixLines(prefix, red("Error fetching stack events")))
+			return
 		}
+
+		var buffer bytes.Buffer
 		for i := len(events) - 1; i >= 0; i-- {
 			e := events[i]
-			// fmt.Println(cursor, e.Timestamp)
 			if cursor.Before(e.Timestamp) {
-				fmt.Printf("%s %s\n  %s %s (%s)\n  %s\n\n",
+				buffer.WriteString(fmt.Sprintf("%s %s\n  %s %s (%s)\n  %s\n\n",
 					e.Timestamp,
 					bold(e.Resource.Status),
 					underline(bold(e.Resource.Type)),
 					cyan(e.Resource.Name),
 					cyan(e.Resource.ID),
 					cyan(e.Resource.StatusReason),
-				)
+				))
 			}
 		}
-		cursor = events[0].Timestamp
+
+		if buffer.Len() > 0 {
+			fmt.Fprint(out, prefixLines(prefix, buffer.String()))
+		}
+
+		if len(events) > 0 {
+			cursor = events[0].Timestamp
+		}
 	}
 }
 
-func reviewStackParams(local client.StackParamInfos, remote client.StackParamInfos) {
-	allKeys := make(map[string]bool)
+// prefixLines prepends a prefix to every line within s
+func prefixLines(prefix string, s string) string {
+	if prefix == "" {
+		return s
+	}
 
-	remoteMap := make(map[string]client.StackParamInfo)
-	for _, p := range remote {
-		remoteMap[p.Key] = p
-		allKeys[p.Key] = true
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
 	}
+	return strings.Join(lines, "")
+}
 
-	localMap := make(map[string]client.StackParamInfo)
+// sensitiveParams returns a function reporting whether a stack parameter is
+// sensitive
+func sensitiveParams(stack stacker.Stack) func(key string) bool {
+	return keySet(stack.SensitiveParams())
+}
+
+// previousParams returns a function reporting whether a parameter of the stack
+// keeps the value it has on the deployed stack
+func previousParams(stack stacker.Stack) func(key string) bool {
+	return keySet(stack.PreviousParams())
+}
+
+// keySet returns a function reporting whether a key is within keys
+func keySet(keys []string) func(key string) bool {
+	set := make(map[string]bool)
+	for _, k := range keys {
+		set[k] = true
+	}
+	return func(key string) bool { return set[key] }
+}
+
+func reviewStackParams(local client.StackParamInfos, remote client.StackParamInfos, sensitive func(key string) bool, previous func(key string) bool) {
+	localMap := make(map[string]string)
 	for _, p := range local {
-		localMap[p.Key] = p
-		allKeys[p.Key] = true
+		localMap[p.Key] = p.Value
+	}
+
+	remoteMap := make(map[string]string)
+	for _, p := range remote {
+		remoteMap[p.Key] = p.Value
 	}
 
-	data := make([][]string, len(allKeys))
+	reviewKeyValues("Stack Params:", localMap, remoteMap, sensitive, previous)
+}
+
+func reviewStackTags(local client.StackTagInfos, remote client.StackTagInfos) {
+	localMap := make(map[string]string)
+	for _, t := range local {
+		localMap[t.Key] = t.Value
+	}
+
+	remoteMap := make(map[string]string)
+	for _, t := range remote {
+		remoteMap[t.Key] = t.Value
+	}
+
+	reviewKeyValues("Stack Tags:", localMap, remoteMap, nil, nil)
+}
+
+// reviewKeyValues prints a table comparing the values of a changeset with
+// those of the existing stack, highlighting values which differ. The values of
+// keys for which sensitive returns true are masked, while keys for which
+// previous returns true are shown as unchanged; either may be nil.
+func reviewKeyValues(title string, local map[string]string, remote map[string]string, sensitive func(key string) bool, previous func(key string) bool) {
+	allKeys := make([]string, 0)
+	for k := range local {
+		allKeys = append(allKeys, k)
+	}
+	for k := range remote {
+		if _, ok := local[k]; !ok {
+			allKeys = append(allKeys, k)
+		}
+	}
+	sort.Strings(allKeys)
+
+	data := make([][]string, 0, len(allKeys)+1)
 	data = append(data, []string{
 		"", bold("changeset"), bold("stack"),
 	})
-	for k, _ := range allKeys {
+	for _, k := range allKeys {
 
-		l := localMap[k].Value
+		l := local[k]
 		if l == "" {
 			l = "<notset>"
 		}
 
-		r := remoteMap[k].Value
+		r := remote[k]
 		if r == "" {
 			r = "<notset>"
 		}
@@ -715,6 +1036,14 @@ func reviewStackParams(local client.StackParamInfos, remote client.StackParamInf
 			c = yellow
 		}
 
+		if sensitive != nil && sensitive(k) {
+			l, r = maskValue(l), maskValue(r)
+		}
+
+		if previous != nil && previous(k) {
+			l, c = "unchanged (previous)", cyan
+		}
+
 		data = append(data, []string{
 			bold(k), c(l), c(r),
 		})
@@ -728,10 +1057,17 @@ func reviewStackParams(local client.StackParamInfos, remote client.StackParamInf
 	table.AppendBulk(data)
 	table.Render()
 
-	fmt.Printf("%s\n%s\n", bold(underline("Stack Params:")), buffer.String())
+	fmt.Printf("%s\n%s\n", bold(underline(title)), buffer.String())
 }
 
-func reviewStackTemplate(oldTemplate, newTemplate string) {
+func maskValue(v string) string {
+	if v == "<notset>" {
+		return v
+	}
+	return client.SensitiveValueMask
+}
+
+func reviewStackTemplate(oldTemplate, newTemplate string) error {
 	diff := difflib.UnifiedDiff{
 		A:        difflib.SplitLines(string(oldTemplate)),
 		B:        difflib.SplitLines(string(newTemplate)),
@@ -741,14 +1077,51 @@ func reviewStackTemplate(oldTemplate, newTemplate string) {
 	}
 	templateDiff, err := difflib.GetUnifiedDiffString(diff)
 	if err != nil {
-		exitWithError(fmt.Errorf("error diffing template: %v", err))
+		return fmt.Errorf("error diffing template: %v", err)
 	}
 
 	fmt.Printf("%s\n\n%s\n", bold(underline("Stack Template:")), templateDiff)
+	return nil
+}
+
+func reviewStackPolicy(oldPolicy, newPolicy string) error {
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(formatPolicy(oldPolicy)),
+		B:        difflib.SplitLines(formatPolicy(newPolicy)),
+		FromFile: "Before",
+		ToFile:   "After",
+		Context:  3,
+	}
+	policyDiff, err := difflib.GetUnifiedDiffString(diff)
+	if err != nil {
+		return fmt.Errorf("error diffing stack policy: %v", err)
+	}
+
+	if policyDiff == "" {
+		policyDiff = "No changes\n"
+	}
+
+	fmt.Printf("%s\n\n%s\n", bold(underline("Stack Policy:")), policyDiff)
+	return nil
+}
+
+// formatPolicy indents a policy document so that policies may be diffed
+// regardless of how they were formatted
+func formatPolicy(policy string) string {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		return policy
+	}
+
+	formatted, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return policy
+	}
+	return string(formatted) + "\n"
 }
 
 func changeSetHasChanges(changeSet *client.ChangeSetInfo) bool {
-	for _, c := range changeSet.Changes {
+	for _, c := range changeSet.AllChanges() {
 		if c.Action == "Modify" || c.Action == "Remove" {
 			return true
 		}
@@ -757,9 +1130,10 @@ func changeSetHasChanges(changeSet *client.ChangeSetInfo) bool {
 	return false
 }
 
-// Determines if a changeset will cause a destructive change
+// Determines if a changeset, or that of any nested stack, will cause a
+// destructive change
 func changeSetIsDestructive(changeSet *client.ChangeSetInfo) bool {
-	for _, c := range changeSet.Changes {
+	for _, c := range changeSet.AllChanges() {
 		if c.Action == "Modify" && c.Replacement {
 			return true
 		}
@@ -0,0 +1,234 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/jawher/mow.cli"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+
+	"github.com/eyeamera/stacker-cli/client"
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+func Deploy(b Backend) func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		var (
+			graph            *stacker.Graph
+			stacks           []stacker.Stack
+			allowDestructive = cmd.Bool(cli.BoolOpt{
+				Name:  "y allow-destructive",
+				Value: false,
+				Desc:  "Allow destructive changes",
+			})
+			concurrency = cmd.Int(cli.IntOpt{
+				Name:  "c concurrency",
+				Value: 1,
+				Desc:  "Maximum number of independent stacks to deploy at once",
+			})
+		)
+
+		cmd.Spec = "[-y] [--allow-destructive] [-c=<n>] [--concurrency=<n>]"
+
+		cmd.Before = func() {
+			var err error
+			if graph, err = fetchGraph(b); err != nil {
+				exitWithError(err)
+			}
+
+			if stacks, err = graph.Sort(); err != nil {
+				exitWithError(err)
+			}
+		}
+
+		cmd.Action = func() {
+			fmt.Printf("%s:\n", bold("Deploying stacks in order"))
+			printStackOrder(stacks)
+
+			// Planning and reviewing a stack requires user input, so only the
+			// application of changesets happens concurrently.
+			interactive := &interactiveOutput{w: os.Stdout}
+
+			skipped, err := graph.Walk(*concurrency, func(stack stacker.Stack) error {
+				err := deploy(stack, *allowDestructive, interactive, interactive)
+				return errors.Wrapf(err, "failed to deploy %s", stack.Name())
+			})
+
+			if err != nil {
+				printSkippedStacks(skipped)
+				exitWithError(err)
+			}
+
+			fmt.Println(bold("Deploy completed successfully"))
+		}
+	}
+}
+
+// fetchGraph fetches all local stacks and builds the graph of their
+// dependencies upon one another
+func fetchGraph(b Backend) (*stacker.Graph, error) {
+	stacks, err := fetchLocal(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch stacks")
+	}
+
+	return stacker.NewGraph(stacks)
+}
+
+// deploy performs a plan, review and an apply on a single stack, returning
+// an error if the stack could not be brought up to date. The plan and review
+// are performed while holding the interactive lock, while the progress of the
+// apply is written to out.
+func deploy(stack stacker.Stack, allowDestructive bool, interactive sync.Locker, out io.Writer) error {
+	stackerCli, err := stackerClient(stack.Region(), stack.Profile())
+	if err != nil {
+		return err
+	}
+
+	cs, err := planAndConfirm(stackerCli, stack, allowDestructive, interactive)
+	if err != nil {
+		return err
+	}
+
+	// Policy and termination protection changes aren't part of a changeset, so
+	// are brought up to date even when the stack has no changes
+	if cs == nil {
+		if _, err := prepareStackPolicy(stackerCli, stack, "", out); err != nil {
+			return err
+		}
+		return updateTerminationProtection(stackerCli, stack, out)
+	}
+
+	if err := applyWithPolicy(stackerCli, stack, cs, "", out, fmt.Sprintf("[%s] ", cyan(stack.Name()))); err != nil {
+		return err
+	}
+
+	si, err := stackerCli.Get(stack.Name())
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch stack information")
+	}
+
+	if si == nil || !si.Succeeded() {
+		status := "DELETED"
+		if si != nil {
+			status = si.Status
+		}
+		return errors.Errorf("stack did not complete successfully. status=%s", status)
+	}
+
+	if err := updateTerminationProtection(stackerCli, stack, out); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s %s\n\n", bold("Stack update completed successfully for"), cyan(stack.Name()))
+
+	return nil
+}
+
+// planAndConfirm creates and reviews a changeset for a stack, returning nil
+// when the stack has no changes to apply
+func planAndConfirm(stackerCli *client.Client, stack stacker.Stack, allowDestructive bool, interactive sync.Locker) (*client.ChangeSetInfo, error) {
+	interactive.Lock()
+	defer interactive.Unlock()
+
+	cs, err := plan(stackerCli, stack)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cs.CanCommit() {
+		if cs.IsEmpty() {
+			fmt.Printf("%s %s\n\n", bold("No changes to apply to"), cyan(stack.Name()))
+			return nil, nil
+		}
+		return nil, errors.Errorf("changeset %s cannot be applied. status=%s reason=%s", cs.Name, cs.Status, cs.StatusReason)
+	}
+
+	if err := review(stackerCli, stack, cs); err != nil {
+		return nil, err
+	}
+
+	if !confirmChanges(cs, allowDestructive) {
+		return nil, errors.New("changes were not confirmed")
+	}
+
+	return cs, nil
+}
+
+// interactiveOutput is the interactive lock of concurrent deploys. Output
+// written to it while the lock is held is buffered until the lock is released,
+// so that the progress of other deploys doesn't interleave with a review and
+// its prompt.
+type interactiveOutput struct {
+	interactive sync.Mutex
+
+	mu     sync.Mutex
+	w      io.Writer
+	held   bool
+	buffer bytes.Buffer
+}
+
+func (o *interactiveOutput) Lock() {
+	o.interactive.Lock()
+
+	o.mu.Lock()
+	o.held = true
+	o.mu.Unlock()
+}
+
+func (o *interactiveOutput) Unlock() {
+	o.mu.Lock()
+	o.held = false
+	o.w.Write(o.buffer.Bytes())
+	o.buffer.Reset()
+	o.mu.Unlock()
+
+	o.interactive.Unlock()
+}
+
+func (o *interactiveOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.held {
+		return o.buffer.Write(p)
+	}
+	return o.w.Write(p)
+}
+
+func printStackOrder(stacks []stacker.Stack) {
+	data := make([][]string, len(stacks))
+	for i, s := range stacks {
+		data[i] = []string{
+			bold(strconv.Itoa(i + 1)),
+			bold(cyan(s.Name())),
+			bold(s.Region()),
+		}
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetColumnSeparator("")
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	fmt.Println()
+}
+
+func printSkippedStacks(stacks []stacker.Stack) {
+	if len(stacks) == 0 {
+		return
+	}
+
+	fmt.Printf("%s:\n", bold(red("Skipped stacks")))
+	for _, s := range stacks {
+		fmt.Printf("  %s (%s)\n", cyan(s.Name()), s.Region())
+	}
+	fmt.Println()
+}
@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	cf "github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+func Destroy(b Backend) func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		var (
+			graph       *stacker.Graph
+			stacks      []stacker.Stack
+			env         = cmd.StringArg("ENV", "", "Environment path, e.g. sandbox or production/vpc")
+			concurrency = cmd.Int(cli.IntOpt{
+				Name:  "c concurrency",
+				Value: 1,
+				Desc:  "Maximum number of independent stacks to delete at once",
+			})
+		)
+
+		cmd.Spec = "ENV [-c=<n>] [--concurrency=<n>]"
+
+		cmd.Before = func() {
+			local, err := b.FetchEnv(*env)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			existing, err := filterExisting(local)
+			if err != nil {
+				exitWithError(errors.Wrap(err, "failed to fetch remote stacks"))
+			}
+
+			if len(existing) == 0 {
+				exitWithError(errors.Errorf("no existing stacks found in `%s`", *env))
+			}
+
+			if err := checkDependents(b, *env, existing); err != nil {
+				exitWithError(err)
+			}
+
+			if err := checkTerminationProtection(existing); err != nil {
+				exitWithError(err)
+			}
+
+			g, err := stacker.NewGraph(existing)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			// Dependents must be deleted before the stacks they depend upon
+			graph = g.Reverse()
+			if stacks, err = graph.Sort(); err != nil {
+				exitWithError(err)
+			}
+		}
+
+		cmd.Action = func() {
+			fmt.Printf("%s: %s\n\n", bold("Destroying environment"), cyan(*env))
+			fmt.Printf("%s:\n", bold("Stacks will be deleted in order"))
+			printStackOrder(stacks)
+
+			fmt.Printf("  %s\n\n",
+				underline("This is a destructive action and will delete every stack above and all of their associated resources!"),
+			)
+
+			if confirm(bold("  Enter environment name to continue: ")) != *env {
+				exitWithError(errors.New("destruction must be confirmed with environment name"))
+			}
+
+			fmt.Println()
+
+			skipped, err := graph.Walk(*concurrency, func(stack stacker.Stack) error {
+				err := destroy(stack)
+				return errors.Wrapf(err, "failed to delete %s", stack.Name())
+			})
+
+			if err != nil {
+				printSkippedStacks(skipped)
+				exitWithError(err)
+			}
+
+			fmt.Println(bold("Environment destroyed successfully"))
+		}
+	}
+}
+
+// filterExisting returns the subset of local stacks which exist remotely
+func filterExisting(local []stacker.Stack) ([]stacker.Stack, error) {
+	statuses, err := compareWithRemote(local)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make([]stacker.Stack, 0)
+	for i, s := range local {
+		if statuses[i] == "" {
+			existing = append(existing, s)
+		}
+	}
+
+	return existing, nil
+}
+
+// checkDependents returns an error when existing stacks outside of the
+// environment depend upon any of the stacks being destroyed. Stacks of every
+// environment are checked, including those outside of the global --env.
+func checkDependents(b Backend, env string, destroying []stacker.Stack) error {
+	all, err := b.FetchEveryEnv()
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch stacks")
+	}
+
+	g, err := stacker.NewGraph(all)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool)
+	for _, s := range destroying {
+		keys[stacker.StackKey(s)] = true
+	}
+
+	dependents := make([]stacker.Stack, 0)
+	seen := make(map[string]bool)
+	for _, s := range g.Stacks() {
+		if !keys[stacker.StackKey(s)] {
+			continue
+		}
+		for _, d := range g.Dependents(s) {
+			if k := stacker.StackKey(d); !keys[k] && !seen[k] {
+				seen[k] = true
+				dependents = append(dependents, d)
+			}
+		}
+	}
+
+	if len(dependents) == 0 {
+		return nil
+	}
+
+	existing, err := filterExisting(dependents)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch remote stacks")
+	}
+
+	if len(existing) == 0 {
+		return nil
+	}
+
+	labels := make([]string, len(existing))
+	for i, s := range existing {
+		labels[i] = graphLabel(s.Name(), s.Region(), s.Profile())
+	}
+	return errors.Errorf(
+		"stacks outside of `%s` depend on stacks which would be destroyed: %s",
+		env, strings.Join(labels, ", "),
+	)
+}
+
+// checkTerminationProtection returns an error when any of the stacks has
+// termination protection enabled, as they can't be deleted
+func checkTerminationProtection(stacks []stacker.Stack) error {
+	protected := make([]string, 0)
+	for _, s := range stacks {
+		stackerCli, err := stackerClient(s.Region(), s.Profile())
+		if err != nil {
+			return err
+		}
+
+		si, err := stackerCli.Get(s.Name())
+		if err != nil {
+			return errors.Wrapf(err, "failed to fetch stack %s", s.Name())
+		}
+
+		if si != nil && si.TerminationProtection {
+			protected = append(protected, graphLabel(s.Name(), s.Region(), s.Profile()))
+		}
+	}
+
+	if len(protected) == 0 {
+		return nil
+	}
+
+	return errors.Errorf(
+		"termination protection is enabled on %s, disable it before destroying the environment",
+		strings.Join(protected, ", "),
+	)
+}
+
+// destroy deletes a single stack, returning an error unless the stack no
+// longer exists once the deletion has finished
+func destroy(stack stacker.Stack) error {
+	stackerCli, err := stackerClient(stack.Region(), stack.Profile())
+	if err != nil {
+		return err
+	}
+
+	// Waiting ends with an error once the stack can no longer be found,
+	// so the outcome is determined from the stack's final state
+	deleteErr := deleteStack(stackerCli, stack.Name(), os.Stdout, fmt.Sprintf("[%s] ", cyan(stack.Name())))
+
+	si, err := stackerCli.Get(stack.Name())
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch stack information")
+	}
+
+	if si == nil || si.Status == cf.StackStatusDeleteComplete {
+		fmt.Printf("%s %s\n\n", bold("Stack deleted successfully:"), cyan(stack.Name()))
+		return nil
+	}
+
+	if deleteErr != nil {
+		return deleteErr
+	}
+
+	return errors.Errorf("stack was not deleted. status=%s", si.Status)
+}
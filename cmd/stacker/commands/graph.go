@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+var graphRenderers = map[string]func(g *stacker.Graph) string{
+	"tree":    renderGraphTree,
+	"dot":     renderGraphDot,
+	"mermaid": renderGraphMermaid,
+}
+
+func Graph(b Backend) func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		var (
+			graph  *stacker.Graph
+			format = cmd.StringOpt("f format", "tree", "Output format: tree, dot or mermaid")
+		)
+
+		cmd.Spec = "[-f=<format>] [--format=<format>]"
+
+		cmd.Before = func() {
+			if _, ok := graphRenderers[*format]; !ok {
+				exitWithError(errors.Errorf("unknown graph format `%s`", *format))
+			}
+
+			var err error
+			if graph, err = fetchGraph(b); err != nil {
+				exitWithError(err)
+			}
+		}
+
+		cmd.Action = func() {
+			fmt.Print(graphRenderers[*format](graph))
+
+			defined, err := b.FetchEveryEnv()
+			if err != nil {
+				exitWithError(errors.Wrap(err, "failed to fetch stacks"))
+			}
+
+			problems := graphProblems(graph, defined)
+			if len(problems) == 0 {
+				return
+			}
+
+			// Problems are written to stderr so they don't corrupt rendered
+			// output which is piped into other tools
+			fmt.Fprintf(os.Stderr, "\n%s:\n", bold(red("Errors")))
+			for _, p := range problems {
+				fmt.Fprintf(os.Stderr, "  %s\n", red(p))
+			}
+			fmt.Fprintln(os.Stderr)
+
+			cli.Exit(1)
+		}
+	}
+}
+
+// graphProblems returns a description of every dependency cycle and every
+// reference to a stack which is not defined in any environment file. Stacks
+// outside of the global --env are given as defined, so references to them
+// aren't reported.
+func graphProblems(g *stacker.Graph, defined []stacker.Stack) []string {
+	problems := make([]string, 0)
+
+	if _, err := g.Sort(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	keys := make(map[string]bool)
+	for _, s := range defined {
+		keys[stacker.StackKey(s)] = true
+	}
+
+	for _, s := range g.Stacks() {
+		for _, ref := range g.Missing(s) {
+			if keys[ref.Key()] {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf(
+				"%s references stack %s which is not defined in any environment file",
+				graphLabel(s.Name(), s.Region(), s.Profile()), graphLabel(ref.Name, ref.Region, ref.Profile),
+			))
+		}
+	}
+
+	return problems
+}
+
+// renderGraphTree renders each stack without dependencies as the root of a
+// tree of the stacks which depend upon it. Stacks which can't be reached from
+// such a root depend upon a cycle, so a stack on each remaining cycle is also
+// rendered as a root, marked as a cycle.
+func renderGraphTree(g *stacker.Graph) string {
+	var (
+		buffer  bytes.Buffer
+		reached = make(map[stacker.Stack]bool)
+	)
+
+	var walk func(s stacker.Stack, indent string, path map[stacker.Stack]bool)
+	walk = func(s stacker.Stack, indent string, path map[stacker.Stack]bool) {
+		reached[s] = true
+		dependents := g.Dependents(s)
+		for i, d := range dependents {
+			branch, next := "├── ", "│   "
+			if i == len(dependents)-1 {
+				branch, next = "└── ", "    "
+			}
+
+			if path[d] {
+				buffer.WriteString(fmt.Sprintf("%s%s%s %s\n", indent, branch, cyan(graphLabel(d.Name(), d.Region(), d.Profile())), red("(cycle)")))
+				continue
+			}
+
+			buffer.WriteString(fmt.Sprintf("%s%s%s\n", indent, branch, cyan(graphLabel(d.Name(), d.Region(), d.Profile()))))
+
+			path[d] = true
+			walk(d, indent+next, path)
+			delete(path, d)
+		}
+	}
+
+	for _, s := range g.Stacks() {
+		if len(g.Dependencies(s)) > 0 {
+			continue
+		}
+
+		buffer.WriteString(fmt.Sprintf("%s\n", bold(cyan(graphLabel(s.Name(), s.Region(), s.Profile())))))
+		walk(s, "", map[stacker.Stack]bool{s: true})
+	}
+
+	for _, s := range g.Stacks() {
+		if reached[s] || !onCycle(g, s) {
+			continue
+		}
+
+		buffer.WriteString(fmt.Sprintf("%s %s\n", bold(cyan(graphLabel(s.Name(), s.Region(), s.Profile()))), red("(cycle)")))
+		walk(s, "", map[stacker.Stack]bool{s: true})
+	}
+
+	return buffer.String()
+}
+
+// onCycle reports whether a stack depends, directly or indirectly, upon itself
+func onCycle(g *stacker.Graph, s stacker.Stack) bool {
+	visited := make(map[stacker.Stack]bool)
+
+	var visit func(d stacker.Stack) bool
+	visit = func(d stacker.Stack) bool {
+		for _, next := range g.Dependents(d) {
+			if next == s {
+				return true
+			}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			if visit(next) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return visit(s)
+}
+
+// renderGraphDot renders the graph in the graphviz DOT language
+func renderGraphDot(g *stacker.Graph) string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("digraph stacks {\n")
+	buffer.WriteString("  rankdir=LR;\n")
+
+	for _, s := range g.Stacks() {
+		buffer.WriteString(fmt.Sprintf("  %s;\n", dotQuote(graphLabel(s.Name(), s.Region(), s.Profile()))))
+	}
+
+	for _, s := range g.Stacks() {
+		label := dotQuote(graphLabel(s.Name(), s.Region(), s.Profile()))
+		for _, d := range g.Dependencies(s) {
+			buffer.WriteString(fmt.Sprintf("  %s -> %s;\n", dotQuote(graphLabel(d.Name(), d.Region(), d.Profile())), label))
+		}
+		for _, ref := range g.Missing(s) {
+			missing := dotQuote(graphLabel(ref.Name, ref.Region, ref.Profile))
+			buffer.WriteString(fmt.Sprintf("  %s [color=red, style=dashed];\n", missing))
+			buffer.WriteString(fmt.Sprintf("  %s -> %s [color=red, style=dashed];\n", missing, label))
+		}
+	}
+
+	buffer.WriteString("}\n")
+
+	return buffer.String()
+}
+
+// renderGraphMermaid renders the graph as a mermaid flowchart
+func renderGraphMermaid(g *stacker.Graph) string {
+	var buffer bytes.Buffer
+
+	ids := make(map[string]string)
+	id := func(name, region, profile string) string {
+		label := graphLabel(name, region, profile)
+		if _, ok := ids[label]; !ok {
+			ids[label] = fmt.Sprintf("s%d", len(ids))
+		}
+		return ids[label]
+	}
+
+	buffer.WriteString("flowchart LR\n")
+
+	for _, s := range g.Stacks() {
+		buffer.WriteString(fmt.Sprintf("  %s[\"%s\"]\n", id(s.Name(), s.Region(), s.Profile()), graphLabel(s.Name(), s.Region(), s.Profile())))
+	}
+
+	for _, s := range g.Stacks() {
+		for _, d := range g.Dependencies(s) {
+			buffer.WriteString(fmt.Sprintf("  %s --> %s\n", id(d.Name(), d.Region(), d.Profile()), id(s.Name(), s.Region(), s.Profile())))
+		}
+		for _, ref := range g.Missing(s) {
+			buffer.WriteString(fmt.Sprintf("  %s[\"%s\"]:::missing -.-> %s\n",
+				id(ref.Name, ref.Region, ref.Profile), graphLabel(ref.Name, ref.Region, ref.Profile), id(s.Name(), s.Region(), s.Profile()),
+			))
+		}
+	}
+
+	buffer.WriteString("  classDef missing stroke:#f00,stroke-dasharray:5\n")
+
+	return buffer.String()
+}
+
+// graphLabel describes a stack by name and region, along with its profile when
+// it's deployed with one
+func graphLabel(name, region, profile string) string {
+	if profile != "" {
+		return fmt.Sprintf("%s (%s, %s)", name, region, profile)
+	}
+	return fmt.Sprintf("%s (%s)", name, region)
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
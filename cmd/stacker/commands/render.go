@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+var renderFormats = map[string]func(v interface{}) ([]byte, error){
+	"yaml": yaml.Marshal,
+	"json": func(v interface{}) ([]byte, error) {
+		b, err := json.MarshalIndent(v, "", "  ")
+		return append(b, '\n'), err
+	},
+}
+
+func Render(b Backend) func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		var (
+			stackName = cmd.StringArg("STACK", "", "Stack name, optionally prefixed with an environment path")
+			format    = cmd.StringOpt("f format", "yaml", "Output format: yaml or json")
+			offline   = cmd.BoolOpt("offline", false, "Show resolver expressions instead of resolving parameters")
+		)
+
+		cmd.Spec = "[-f=<format>] [--format=<format>] [--offline] STACK"
+
+		cmd.Before = func() {
+			if _, ok := renderFormats[*format]; !ok {
+				exitWithError(errors.Errorf("unknown render format `%s`", *format))
+			}
+		}
+
+		cmd.Action = func() {
+			rendered, err := b.Render(fetchStack(b, *stackName), *offline)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			out, err := renderFormats[*format](rendered)
+			if err != nil {
+				exitWithError(errors.Wrap(err, "unable to render stack"))
+			}
+
+			fmt.Print(string(out))
+		}
+	}
+}
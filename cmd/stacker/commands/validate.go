@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
+
+	"github.com/eyeamera/stacker-cli/stacker"
+)
+
+func Validate(b Backend) func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		var (
+			stackName = cmd.StringArg("STACK", "", "Stack name, optionally prefixed with an environment path")
+			resolve   = cmd.BoolOpt("resolve", false, "Also resolve each stack's parameters and validate them against its template")
+		)
+
+		cmd.Spec = "[--resolve [STACK]]"
+
+		cmd.Action = func() {
+			problems, err := b.Check()
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if len(problems) > 0 {
+				fmt.Fprintf(os.Stderr, "%s:\n", bold(red("Problems")))
+				for _, p := range problems {
+					fmt.Fprintf(os.Stderr, "  %s\n", red(p))
+				}
+				fmt.Fprintln(os.Stderr)
+				exitWithError(errors.Errorf("%d problems found", len(problems)))
+			}
+
+			fmt.Println(bold("Environment files and templates are valid"))
+
+			if !*resolve {
+				return
+			}
+
+			var stacks []stacker.Stack
+			if *stackName != "" {
+				stacks = []stacker.Stack{fetchStack(b, *stackName)}
+			} else if stacks, err = fetchLocal(b); err != nil {
+				exitWithError(err)
+			}
+
+			failed := 0
+			for _, stack := range stacks {
+				if err := validate(stack); err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n\n", red(err))
+					failed++
+					continue
+				}
+				fmt.Printf("%s %s\n", bold("Stack is valid:"), cyan(stack.Name()))
+			}
+
+			if failed > 0 {
+				exitWithError(errors.Errorf("%d of %d stacks failed validation", failed, len(stacks)))
+			}
+		}
+	}
+}
+
+// validate checks a stack's parameters against its template, printing any
+// warnings about parameters the template doesn't use
+func validate(stack stacker.Stack) error {
+	warnings, err := stack.Validate()
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", yellow(fmt.Sprintf("Warning [%s]", stack.Name())), w)
+	}
+
+	return errors.Wrapf(err, "stack %s failed validation", stack.Name())
+}
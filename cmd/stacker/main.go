@@ -18,10 +18,25 @@ func main() {
 
 	app := cli.App("stacker", "Manage Cloudformation Stacks")
 
+	env := app.String(cli.StringOpt{
+		Name:   "e env",
+		EnvVar: "STACKER_ENV",
+		Desc:   "Limit stacks to an environment path, e.g. production or production/vpc",
+	})
+
+	app.Before = func() {
+		b.SetEnv(*env)
+	}
+
 	app.Command("list", "List available stacks", commands.List(b))
+	app.Command("graph", "Show the dependencies between stacks", commands.Graph(b))
+	app.Command("validate", "Validate environment files, templates and stack parameters", commands.Validate(b))
+	app.Command("deploy", "Deploy performs an update on every stack in dependency order", commands.Deploy(b))
+	app.Command("destroy", "Delete every stack within an environment in reverse dependency order", commands.Destroy(b))
 
 	// Require a stack
 	app.Command("show", "Show information about a stack", commands.Show(b))
+	app.Command("render", "Render the effective configuration of a stack", commands.Render(b))
 	app.Command("plan", "Plan a change to a stack by creating a changeset", commands.Plan(b))
 	app.Command("review", "Review a changeset", commands.Review(b))
 	app.Command("apply", "Apply a changeset", commands.Apply(b))
@@ -1,29 +1,28 @@
-module github.com/cotap/stacker-cli
+module github.com/eyeamera/stacker-cli
 
 go 1.20
 
 require (
-	github.com/aws/aws-sdk-go v1.12.70
+	github.com/aws/aws-sdk-go v1.35.37
 	github.com/awslabs/goformation v1.1.0
 	github.com/davecgh/go-spew v1.1.0
 	github.com/fatih/color v1.5.0
 	github.com/go-ini/ini v1.32.0
 	github.com/imdario/mergo v0.3.6
 	github.com/jawher/mow.cli v1.0.3
-	github.com/jmespath/go-jmespath v0.0.0-20160202185014-0b12d6b521d8
+	github.com/jmespath/go-jmespath v0.4.0
 	github.com/mattn/go-colorable v0.0.9
 	github.com/mattn/go-isatty v0.0.3
 	github.com/mattn/go-runewidth v0.0.2
 	github.com/mitchellh/mapstructure v0.0.0-20180111000720-b4575eea38cc
 	github.com/olekukonko/tablewriter v0.0.0-20180130162743-b8a9be070da4
-	github.com/pkg/errors v0.8.0
+	github.com/pkg/errors v0.9.1
 	github.com/pmezard/go-difflib v1.0.0
 	github.com/sanathkr/go-yaml v0.0.0-20170819195128-ed9d249f429b
 	github.com/sanathkr/yaml v1.0.0
 	github.com/stretchr/objx v0.1.0
 	github.com/stretchr/testify v1.2.1
-	golang.org/x/sys v0.0.0-20180201153126-8f27ce8a6040
-	gopkg.in/yaml.v2 v2.0.0
+	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f
+	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v3 v3.0.1
 )
-
-require github.com/eyeamera/stacker-cli v0.0.0-20200303150347-7244571be392
@@ -0,0 +1,285 @@
+package stacker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph describes the dependencies between a set of stacks, as implied by
+// the stack output references within each stack's parameters
+type Graph struct {
+	stacks  map[string]Stack
+	deps    map[string][]string // stack key => keys of the stacks it depends on
+	missing map[string][]StackRef
+}
+
+// NewGraph builds a dependency graph for the provided stacks, returning an
+// error when a stack is provided more than once. References to stacks which
+// are not part of the provided list, in any profile, are recorded as missing.
+func NewGraph(stacks []Stack) (*Graph, error) {
+	g := &Graph{
+		stacks:  make(map[string]Stack),
+		deps:    make(map[string][]string),
+		missing: make(map[string][]StackRef),
+	}
+
+	for _, s := range stacks {
+		k := StackKey(s)
+		if _, ok := g.stacks[k]; ok {
+			return nil, fmt.Errorf("stack %s is defined more than once", k)
+		}
+		g.stacks[k] = s
+	}
+
+	for k, s := range g.stacks {
+		seen := make(map[string]bool)
+		for _, ref := range s.Dependencies() {
+			dk := ref.Key()
+			if seen[dk] {
+				continue
+			}
+			seen[dk] = true
+
+			if _, ok := g.stacks[dk]; !ok {
+				g.missing[k] = append(g.missing[k], ref)
+				continue
+			}
+			g.deps[k] = append(g.deps[k], dk)
+		}
+		sort.Strings(g.deps[k])
+	}
+
+	return g, nil
+}
+
+// Stacks returns every stack within the graph, sorted by name
+func (g *Graph) Stacks() []Stack {
+	return g.lookup(g.sortedKeys())
+}
+
+// Dependencies returns the stacks which the provided stack depends upon
+func (g *Graph) Dependencies(s Stack) []Stack {
+	return g.lookup(g.deps[StackKey(s)])
+}
+
+// Dependents returns the stacks which directly depend upon the provided stack
+func (g *Graph) Dependents(s Stack) []Stack {
+	key := StackKey(s)
+
+	keys := make([]string, 0)
+	for _, k := range g.sortedKeys() {
+		for _, dk := range g.deps[k] {
+			if dk == key {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+
+	return g.lookup(keys)
+}
+
+// Missing returns the references made by a stack to stacks which are not
+// part of the graph
+func (g *Graph) Missing(s Stack) []StackRef {
+	return g.missing[StackKey(s)]
+}
+
+// Reverse returns a graph with every dependency inverted, such that each stack
+// depends upon the stacks which depended upon it. References to missing stacks
+// are not carried over.
+func (g *Graph) Reverse() *Graph {
+	r := &Graph{
+		stacks:  g.stacks,
+		deps:    make(map[string][]string),
+		missing: make(map[string][]StackRef),
+	}
+
+	for k, deps := range g.deps {
+		for _, dk := range deps {
+			r.deps[dk] = append(r.deps[dk], k)
+		}
+	}
+
+	for k := range r.deps {
+		sort.Strings(r.deps[k])
+	}
+
+	return r
+}
+
+// Sort returns the stacks in dependency order, such that every stack appears
+// after all of the stacks it depends upon. An error is returned when the
+// dependencies contain a cycle.
+func (g *Graph) Sort() ([]Stack, error) {
+	pending := make(map[string]int)
+	for k := range g.stacks {
+		pending[k] = len(g.deps[k])
+	}
+
+	sorted := make([]string, 0, len(g.stacks))
+	for len(pending) > 0 {
+		ready := make([]string, 0)
+		for k, n := range pending {
+			if n == 0 {
+				ready = append(ready, k)
+			}
+		}
+
+		if len(ready) == 0 {
+			return nil, fmt.Errorf("dependency cycle detected between stacks: %s", g.describeCycle(pending))
+		}
+
+		sort.Strings(ready)
+		for _, k := range ready {
+			delete(pending, k)
+			sorted = append(sorted, k)
+		}
+
+		for k := range pending {
+			pending[k] = 0
+			for _, dk := range g.deps[k] {
+				if _, ok := pending[dk]; ok {
+					pending[k]++
+				}
+			}
+		}
+	}
+
+	return g.lookup(sorted), nil
+}
+
+// Walk calls fn for every stack in dependency order, running up to
+// concurrency calls at once. A stack is only visited after all of the stacks
+// it depends upon have been visited successfully. Once a call fails no further
+// stacks are started; the first error is returned along with the stacks which
+// were never visited.
+func (g *Graph) Walk(concurrency int, fn func(Stack) error) ([]Stack, error) {
+	order, err := g.Sort()
+	if err != nil {
+		return nil, err
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	type result struct {
+		key string
+		err error
+	}
+
+	var (
+		started  = make(map[string]bool)
+		done     = make(map[string]bool)
+		results  = make(chan result)
+		running  int
+		firstErr error
+	)
+
+	for {
+		for _, s := range order {
+			if firstErr != nil || running >= concurrency {
+				break
+			}
+
+			k := StackKey(s)
+			if started[k] || !g.ready(k, done) {
+				continue
+			}
+
+			started[k] = true
+			running++
+			go func(k string, s Stack) {
+				results <- result{key: k, err: fn(s)}
+			}(k, s)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		done[r.key] = true
+	}
+
+	skipped := make([]Stack, 0)
+	for _, s := range order {
+		if !started[StackKey(s)] {
+			skipped = append(skipped, s)
+		}
+	}
+
+	return skipped, firstErr
+}
+
+// ready returns whether every dependency of a stack has been visited
+func (g *Graph) ready(key string, done map[string]bool) bool {
+	for _, dk := range g.deps[key] {
+		if !done[dk] {
+			return false
+		}
+	}
+	return true
+}
+
+// describeCycle names the stacks which take part in a cycle, given the stacks
+// which could not be sorted. Stacks which merely depend upon a cycle are
+// pruned by repeatedly removing stacks which nothing else depends on.
+func (g *Graph) describeCycle(pending map[string]int) string {
+	remaining := make(map[string]bool)
+	for k := range pending {
+		remaining[k] = true
+	}
+
+	for pruned := true; pruned; {
+		pruned = false
+
+		required := make(map[string]bool)
+		for k := range remaining {
+			for _, dk := range g.deps[k] {
+				required[dk] = true
+			}
+		}
+
+		for k := range remaining {
+			if !required[k] {
+				delete(remaining, k)
+				pruned = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(remaining))
+	for k := range remaining {
+		names = append(names, g.stacks[k].Name())
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func (g *Graph) sortedKeys() []string {
+	keys := make([]string, 0, len(g.stacks))
+	for k := range g.stacks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (g *Graph) lookup(keys []string) []Stack {
+	stacks := make([]Stack, len(keys))
+	for i, k := range keys {
+		stacks[i] = g.stacks[k]
+	}
+	return stacks
+}
@@ -0,0 +1,186 @@
+package stacker
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStack struct {
+	name    string
+	region  string
+	profile string
+	deps    []string
+	refs    []StackRef
+}
+
+func (s *fakeStack) Name() string                  { return s.name }
+func (s *fakeStack) Region() string                { return s.region }
+func (s *fakeStack) Profile() string               { return s.profile }
+func (s *fakeStack) Params() ([]StackParam, error) { return nil, nil }
+func (s *fakeStack) SensitiveParams() []string     { return nil }
+func (s *fakeStack) PreviousParams() []string      { return nil }
+func (s *fakeStack) TemplateBody() (string, error) { return "", nil }
+func (s *fakeStack) Capabilities() []string        { return nil }
+func (s *fakeStack) Tags() map[string]string       { return nil }
+func (s *fakeStack) RoleARN() string               { return "" }
+func (s *fakeStack) NotificationARNs() []string    { return nil }
+func (s *fakeStack) TerminationProtection() *bool  { return nil }
+func (s *fakeStack) StackPolicy() string           { return "" }
+func (s *fakeStack) Validate() ([]string, error)   { return nil, nil }
+func (s *fakeStack) ArtifactBucket() *ArtifactBucket {
+	return nil
+}
+func (s *fakeStack) RollbackConfiguration() *RollbackConfiguration {
+	return nil
+}
+func (s *fakeStack) Dependencies() []StackRef {
+	refs := make([]StackRef, len(s.deps))
+	for i, d := range s.deps {
+		refs[i] = StackRef{Name: d, Region: s.region, Profile: s.profile}
+	}
+	return append(refs, s.refs...)
+}
+
+func names(stacks []Stack) []string {
+	n := make([]string, len(stacks))
+	for i, s := range stacks {
+		n[i] = s.Name()
+	}
+	return n
+}
+
+func TestGraphSort(t *testing.T) {
+	vpc := &fakeStack{name: "VPC", region: "us-east-1"}
+	subnetA := &fakeStack{name: "SubnetA", region: "us-east-1", deps: []string{"VPC"}}
+	subnetB := &fakeStack{name: "SubnetB", region: "us-east-1", deps: []string{"VPC", "VPC"}}
+	api := &fakeStack{name: "API", region: "us-east-1", deps: []string{"SubnetA", "SubnetB", "External"}}
+	other := &fakeStack{name: "VPC", region: "us-west-2"}
+
+	g, err := NewGraph([]Stack{api, subnetB, other, subnetA, vpc})
+	assert.Nil(t, err)
+
+	sorted, err := g.Sort()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"VPC", "VPC", "SubnetA", "SubnetB", "API"}, names(sorted))
+	assert.Equal(t, []Stack{subnetA, subnetB}, g.Dependents(vpc))
+	assert.Equal(t, []Stack{subnetA, subnetB}, g.Dependencies(api))
+	assert.Equal(t, []StackRef{{Name: "External", Region: "us-east-1"}}, g.Missing(api))
+	assert.Empty(t, g.Dependents(other))
+}
+
+func TestGraphProfiles(t *testing.T) {
+	vpc := &fakeStack{name: "VPC", region: "us-east-1"}
+	sharedVPC := &fakeStack{name: "VPC", region: "us-east-1", profile: "shared"}
+	subnet := &fakeStack{name: "Subnet", region: "us-east-1", profile: "shared", deps: []string{"VPC"}}
+	api := &fakeStack{name: "API", region: "us-east-1", refs: []StackRef{
+		{Name: "VPC", Region: "us-east-1", Profile: "shared"},
+		{Name: "Certificates", Region: "us-east-1", Profile: "global"},
+	}}
+
+	g, err := NewGraph([]Stack{api, subnet, sharedVPC, vpc})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []Stack{sharedVPC}, g.Dependencies(subnet))
+	assert.Equal(t, []Stack{api, subnet}, g.Dependents(sharedVPC))
+	assert.Empty(t, g.Dependents(vpc))
+
+	// References into other accounts order deploys like any other
+	assert.Equal(t, []Stack{sharedVPC}, g.Dependencies(api))
+	assert.Equal(t, []StackRef{{Name: "Certificates", Region: "us-east-1", Profile: "global"}}, g.Missing(api))
+}
+
+func TestGraphDuplicate(t *testing.T) {
+	vpc := &fakeStack{name: "VPC", region: "us-east-1"}
+	duplicate := &fakeStack{name: "VPC", region: "us-east-1"}
+	shared := &fakeStack{name: "VPC", region: "us-east-1", profile: "shared"}
+
+	g, err := NewGraph([]Stack{vpc, shared, duplicate})
+
+	assert.Nil(t, g)
+	assert.EqualError(t, err, "stack VPC@us-east-1 is defined more than once")
+}
+
+func TestGraphReverse(t *testing.T) {
+	vpc := &fakeStack{name: "VPC", region: "us-east-1"}
+	subnet := &fakeStack{name: "Subnet", region: "us-east-1", deps: []string{"VPC", "External"}}
+	api := &fakeStack{name: "API", region: "us-east-1", deps: []string{"Subnet", "VPC"}}
+
+	g, err := NewGraph([]Stack{vpc, subnet, api})
+	assert.Nil(t, err)
+
+	r := g.Reverse()
+
+	sorted, err := r.Sort()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"API", "Subnet", "VPC"}, names(sorted))
+	assert.Equal(t, []Stack{api, subnet}, r.Dependencies(vpc))
+	assert.Empty(t, r.Missing(subnet))
+}
+
+func TestGraphSortCycle(t *testing.T) {
+	a := &fakeStack{name: "A", region: "us-east-1", deps: []string{"B"}}
+	b := &fakeStack{name: "B", region: "us-east-1", deps: []string{"A"}}
+	c := &fakeStack{name: "C", region: "us-east-1"}
+	d := &fakeStack{name: "D", region: "us-east-1", deps: []string{"A", "C"}}
+
+	g, err := NewGraph([]Stack{a, b, c, d})
+	assert.Nil(t, err)
+
+	sorted, err := g.Sort()
+
+	assert.Nil(t, sorted)
+	assert.EqualError(t, err, "dependency cycle detected between stacks: A, B")
+}
+
+func TestGraphWalk(t *testing.T) {
+	vpc := &fakeStack{name: "VPC", region: "us-east-1"}
+	subnetA := &fakeStack{name: "SubnetA", region: "us-east-1", deps: []string{"VPC"}}
+	subnetB := &fakeStack{name: "SubnetB", region: "us-east-1", deps: []string{"VPC"}}
+	api := &fakeStack{name: "API", region: "us-east-1", deps: []string{"SubnetA", "SubnetB"}}
+
+	g, err := NewGraph([]Stack{api, subnetA, subnetB, vpc})
+	assert.Nil(t, err)
+
+	var (
+		mu      sync.Mutex
+		visited []string
+	)
+
+	skipped, err := g.Walk(2, func(s Stack) error {
+		mu.Lock()
+		defer mu.Unlock()
+		visited = append(visited, s.Name())
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, skipped)
+	assert.Equal(t, "VPC", visited[0])
+	assert.ElementsMatch(t, []string{"SubnetA", "SubnetB"}, visited[1:3])
+	assert.Equal(t, "API", visited[3])
+}
+
+func TestGraphWalkFailure(t *testing.T) {
+	vpc := &fakeStack{name: "VPC", region: "us-east-1"}
+	subnetA := &fakeStack{name: "SubnetA", region: "us-east-1", deps: []string{"VPC"}}
+	subnetB := &fakeStack{name: "SubnetB", region: "us-east-1", deps: []string{"VPC"}}
+	api := &fakeStack{name: "API", region: "us-east-1", deps: []string{"SubnetA", "SubnetB"}}
+
+	g, err := NewGraph([]Stack{api, subnetA, subnetB, vpc})
+	assert.Nil(t, err)
+
+	skipped, err := g.Walk(1, func(s Stack) error {
+		if s.Name() == "SubnetA" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, []string{"SubnetB", "API"}, names(skipped))
+}
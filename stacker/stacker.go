@@ -1,10 +1,12 @@
 package stacker
 
-// StackParam represents a stack parameter
+// StackParam represents a stack parameter. Sensitive parameters hold secrets
+// which must not be displayed.
 type StackParam interface {
 	Key() string
 	Value() string
 	UsePrevious() bool
+	Sensitive() bool
 }
 
 // StackParams represents an set of StackParam
@@ -14,9 +16,90 @@ type StackParams []StackParam
 type Stack interface {
 	Name() string
 	Region() string
+	Profile() string
 	Params() ([]StackParam, error)
-	TemplateBody() string
+	SensitiveParams() []string
+	PreviousParams() []string
+	TemplateBody() (string, error)
 	Capabilities() []string
+	Tags() map[string]string
+	RoleARN() string
+	NotificationARNs() []string
+	RollbackConfiguration() *RollbackConfiguration
+	TerminationProtection() *bool
+	StackPolicy() string
+	ArtifactBucket() *ArtifactBucket
+	Dependencies() []StackRef
+	Validate() ([]string, error)
+}
+
+// RollbackConfiguration describes the alarms cloudformation monitors while
+// creating or updating a stack, rolling the stack back if any go into alarm
+type RollbackConfiguration struct {
+	MonitoringTimeInMinutes int64    `json:"monitoring_time" yaml:"monitoring_time"`
+	AlarmARNs               []string `json:"alarms" yaml:"alarms"`
+}
+
+// ArtifactBucket is an S3 bucket which templates are uploaded to when they're
+// too large to send inline, or always when Always is set. Local artifacts
+// referenced by templates are also uploaded to it.
+type ArtifactBucket struct {
+	Name   string `json:"name" yaml:"name"`
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Always bool   `json:"always,omitempty" yaml:"always,omitempty"`
+}
+
+// StackRef references a stack by name within a region, and within the account
+// of a named profile from the shared aws config when Profile is set
+type StackRef struct {
+	Name    string
+	Region  string
+	Profile string
+}
+
+// Key identifies the referenced stack by name, region and profile, as stacks
+// with the same name may exist in several regions and accounts
+func (ref StackRef) Key() string {
+	key := ref.Name + "@" + ref.Region
+	if ref.Profile != "" {
+		key += "/" + ref.Profile
+	}
+	return key
+}
+
+// StackKey identifies a stack in the same way as the key of a reference to it
+func StackKey(s Stack) string {
+	return StackRef{Name: s.Name(), Region: s.Region(), Profile: s.Profile()}.Key()
+}
+
+// RenderedStack is the effective configuration of a stack, once defaults have
+// been inherited from parent environment files
+type RenderedStack struct {
+	Name                  string                   `json:"name" yaml:"name"`
+	File                  string                   `json:"file" yaml:"file"`
+	Region                string                   `json:"region" yaml:"region"`
+	Profile               string                   `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Template              string                   `json:"template" yaml:"template"`
+	Capabilities          []string                 `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	RoleARN               string                   `json:"role_arn,omitempty" yaml:"role_arn,omitempty"`
+	NotificationARNs      []string                 `json:"notification_arns,omitempty" yaml:"notification_arns,omitempty"`
+	RollbackConfiguration *RollbackConfiguration   `json:"rollback_configuration,omitempty" yaml:"rollback_configuration,omitempty"`
+	TerminationProtection *bool                    `json:"termination_protection,omitempty" yaml:"termination_protection,omitempty"`
+	StackPolicy           interface{}              `json:"stack_policy,omitempty" yaml:"stack_policy,omitempty"` // Policy document
+	ArtifactBucket        *ArtifactBucket          `json:"artifact_bucket,omitempty" yaml:"artifact_bucket,omitempty"`
+	Tags                  map[string]string        `json:"tags,omitempty" yaml:"tags,omitempty"`
+	TemplateVars          map[string]interface{}   `json:"template_vars,omitempty" yaml:"template_vars,omitempty"`
+	Parameters            map[string]RenderedParam `json:"parameters" yaml:"parameters"`
+	UnusedParameters      []string                 `json:"unused_parameters,omitempty" yaml:"unused_parameters,omitempty"` // Not declared by the template
+}
+
+// RenderedParam is a parameter's value along with the environment file it's
+// set in. Unresolved values hold the resolver expression from the file.
+type RenderedParam struct {
+	Value     interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+	Previous  bool        `json:"previous,omitempty" yaml:"previous,omitempty"`
+	Sensitive bool        `json:"sensitive,omitempty" yaml:"sensitive,omitempty"`
+	Source    string      `json:"source" yaml:"source"`
 }
 
 // Sortable list of Stacks
@@ -0,0 +1,3 @@
+const util = require('./lib/util');
+
+exports.handler = async () => util.greet('world');